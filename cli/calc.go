@@ -0,0 +1,199 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package cli for command-line interface (headless front end)
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/serdug/kitri/conti"
+	"github.com/serdug/kitri/handlers"
+)
+
+// calc runs the 'calc' command: reads a template, calculates accounts and
+// writes results in the CSV format, or in the format of the output file by
+// its extension: an Excel workbook ('.xlsx') or an HTML page ('.html').
+// The trial balance verdict is printed to stderr; the statements may be
+// written to a CSV file of their own.
+func calc(args []string, stdout, stderr io.Writer) int {
+	var (
+		output     string
+		statements string
+		depth      int
+	)
+
+	fs := flag.NewFlagSet("calc", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&output, "o", "", "write results to a `file` instead of the standard output")
+	fs.IntVar(&depth, "depth", 0, "show categories to `n` levels of the hierarchy, all levels if 0")
+	fs.StringVar(&statements, "statements", "", "also write the trial balance verdict and the statements as CSV to a `file`")
+
+	template, ok := parseArgs(fs, args)
+	if !ok {
+		return ExitUsage
+	}
+
+	s, status := readTemplate(template, stderr)
+	if status != ExitOK {
+		return status
+	}
+
+	cats, rep, alert := conti.AccountsReport(s)
+	if len(alert.Code) != 0 {
+		reportAlert(stderr, alert)
+		return ExitError
+	}
+	reportNotes(stderr, alert)
+	reportVerdict(stderr, rep)
+
+	if statements != "" {
+		if err := writeStatements(statements, cats, rep, depth); err != nil {
+			fmt.Fprintln(stderr, "Writing error:", err)
+			return ExitError
+		}
+	}
+
+	if output == "" {
+		err := conti.WriteAccountsCsv(stdout, cats, rep, depth)
+		if err != nil {
+			fmt.Fprintln(stderr, "Writing error:", err)
+			return ExitError
+		}
+		return ExitOK
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		fmt.Fprintln(stderr, "Writing error:", err)
+		return ExitError
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(output)) {
+	case ".xlsx":
+		err = conti.WriteAccountsXlsx(f, cats, rep, depth)
+
+	case ".html", ".htm":
+		err = conti.WriteAccountsHtml(f, cats, rep, depth)
+
+	default:
+		err = conti.WriteAccountsCsv(f, cats, rep, depth)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "Writing error:", err)
+		return ExitError
+	}
+	return ExitOK
+}
+
+// reportVerdict prints the trial balance verdict and the imbalances, if any
+func reportVerdict(stderr io.Writer, rep conti.Report) {
+	verdict := conti.TrialBalance(rep)
+	fmt.Fprintln(stderr, verdict.Banner())
+	for _, d := range verdict.Imbalances {
+		fmt.Fprintln(stderr, d.String())
+	}
+}
+
+// writeStatements writes the trial balance verdict and the statements in
+// the CSV format to a file
+func writeStatements(filename string, cats []conti.Categories, rep conti.Report, depth int) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return conti.WriteStatementsCsv(f, cats, rep, depth)
+}
+
+// parseArgs parses flags placed before and after the template name and
+// returns the template name
+func parseArgs(fs *flag.FlagSet, args []string) (string, bool) {
+	if err := fs.Parse(args); err != nil {
+		return "", false
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(fs.Output(), "No template provided")
+		return "", false
+	}
+	template := fs.Arg(0)
+
+	// Note: flags may follow the template name, e.g. 'calc t.yaml -o out.csv'
+	if err := fs.Parse(fs.Args()[1:]); err != nil {
+		return "", false
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintln(fs.Output(), "Unexpected arguments:", strings.Join(fs.Args(), " "))
+		return "", false
+	}
+	return template, true
+}
+
+// readTemplate reads a schema template from a YAML or JSON file, upgraded
+// to the current version; the problems found in the template and a note of
+// the upgrade are printed to stderr. The exit status is ExitUsage if the
+// template can't be read by its name, ExitError if its content is wrong.
+func readTemplate(filename string, stderr io.Writer) (conti.Schema, int) {
+	var (
+		s     conti.Schema
+		alert conti.NoticeOfError
+	)
+
+	if _, err := os.Stat(filename); err != nil {
+		fmt.Fprintln(stderr, "Template not found:", filename)
+		return s, ExitUsage
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		s, alert = handlers.ReadSchemaJSON(filename)
+
+	case ".yaml", ".yml":
+		s, alert = handlers.ReadSchemaYAML(filename)
+
+	default:
+		fmt.Fprintf(stderr, "Template '%s' has an unacceptable extension; use .yaml, .yml or .json\n", filename)
+		return s, ExitUsage
+	}
+
+	if alert.Err != nil {
+		reportAlert(stderr, alert)
+		return s, ExitError
+	}
+	reportNotes(stderr, alert)
+	return s, ExitOK
+}
+
+// reportAlert prints a notice of error after the warnings and notes found
+// on the way
+func reportAlert(w io.Writer, alert conti.NoticeOfError) {
+	reportNotes(w, alert)
+	fmt.Fprintln(w, "Error:", alert.Code)
+	if alert.Resource != "" {
+		fmt.Fprintln(w, "Resource:", alert.Resource)
+	}
+	if alert.Hint != "" {
+		fmt.Fprintln(w, "Hint:", alert.Hint)
+	}
+	if alert.Err != nil {
+		fmt.Fprintln(w, "Cause:", alert.Err)
+	}
+}
+
+// reportNotes prints the warnings and notes found in the input; errors are
+// listed in the hint of the notice
+func reportNotes(w io.Writer, alert conti.NoticeOfError) {
+	for _, d := range alert.Diagnostics {
+		if d.Severity != conti.SeverityError {
+			fmt.Fprintln(w, d)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package cli for command-line interface (headless front end)
+package cli
+
+import (
+	"fmt"
+	"io"
+)
+
+// Exit statuses
+const (
+	ExitOK    = 0 // Success
+	ExitError = 1 // Wrong template, or calculation has gone not as expected
+	ExitUsage = 2 // Wrong command or arguments
+)
+
+const usage = `Usage:
+  kitri                             launch the graphical interface
+  kitri calc [-o file] [-depth n] [-statements file] template
+                                    calculate accounts defined in a template
+  kitri close [-o file] template    write records closing the P&L accounts
+  kitri roll -o file [-f] template  write charts and a template of the next
+                                    period
+  kitri ledger [-o file] [-cat ids] template
+                                    list records posted to categories
+  kitri schema [-o file]            write the JSON Schema of templates
+
+Commands:
+  calc    reads a .yaml, .yml or .json template, runs the calculation and
+          writes results as CSV to the standard output or to a file (-o);
+          a file with an .xlsx extension is written as an Excel workbook,
+          with an .html extension as a page of financial statements
+          -depth n shows categories to n levels of the hierarchy
+          -statements file also writes the trial balance verdict and the
+          statements as CSV; the verdict is printed to the standard error
+  close   calculates accounts and writes records which carry the ending
+          values of the P&L categories into the closing category of the
+          template (e.g. 'closing: 320') as CSV to the standard output or
+          to a file (-o); add the file to the records of the template to
+          close the period
+  roll    calculates accounts, closes the P&L categories as 'close' does
+          and writes the template of the next period (-o) with a chart
+          file per section next to it: ending values become the starting
+          balances and the list of records is empty; existing files are
+          kept unless -f is set
+  ledger  calculates accounts and writes, per category, the records posted
+          in the reporting period with their files, rows, counter-accounts,
+          signed amounts and running balances as CSV to the standard output
+          or to a file (-o); -cat lists comma-separated categories, all
+          categories with records are listed by default
+  schema  writes the JSON Schema of templates of the current version to
+          the standard output or to a file (-o), for editors to validate
+          and complete templates; older templates are upgraded on load
+  help    shows this message
+`
+
+// Run executes a command given in args (without the program name) and
+// returns the exit status
+func Run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return ExitUsage
+	}
+
+	switch args[0] {
+	case "calc":
+		return calc(args[1:], stdout, stderr)
+
+	case "close":
+		return closing(args[1:], stdout, stderr)
+
+	case "roll":
+		return roll(args[1:], stdout, stderr)
+
+	case "ledger":
+		return ledger(args[1:], stdout, stderr)
+
+	case "schema":
+		return schema(args[1:], stdout, stderr)
+
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return ExitOK
+
+	default:
+		fmt.Fprintf(stderr, "Unknown command '%s'\n\n", args[0])
+		fmt.Fprint(stderr, usage)
+		return ExitUsage
+	}
+}
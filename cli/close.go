@@ -0,0 +1,61 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package cli for command-line interface (headless front end)
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/serdug/kitri/conti"
+)
+
+// closing runs the 'close' command: reads a template, calculates accounts and
+// writes the closing records, which carry the categories of the Profit &
+// Loss Statement into the closing category set in the template, as a CSV
+// file of records
+func closing(args []string, stdout, stderr io.Writer) int {
+	var output string
+
+	fs := flag.NewFlagSet("close", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&output, "o", "", "write records to a `file` instead of the standard output")
+
+	template, ok := parseArgs(fs, args)
+	if !ok {
+		return ExitUsage
+	}
+
+	s, status := readTemplate(template, stderr)
+	if status != ExitOK {
+		return status
+	}
+
+	recs, _, _, alert := conti.AccountsClose(s)
+	if len(alert.Code) != 0 {
+		reportAlert(stderr, alert)
+		return ExitError
+	}
+	reportNotes(stderr, alert)
+
+	w := stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			fmt.Fprintln(stderr, "Writing error:", err)
+			return ExitError
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := conti.WriteRecordsCsv(w, recs); err != nil {
+		fmt.Fprintln(stderr, "Writing error:", err)
+		return ExitError
+	}
+	return ExitOK
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package cli for command-line interface (headless front end)
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/serdug/kitri/conti"
+)
+
+// ledger runs the 'ledger' command: reads a template, calculates accounts
+// and writes the records posted to categories with running balances in the
+// CSV format
+func ledger(args []string, stdout, stderr io.Writer) int {
+	var (
+		output string
+		cats   string
+	)
+
+	fs := flag.NewFlagSet("ledger", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&output, "o", "", "write the ledger to a `file` instead of the standard output")
+	fs.StringVar(&cats, "cat", "", "list comma-separated `categories`, all categories with records if empty")
+
+	template, ok := parseArgs(fs, args)
+	if !ok {
+		return ExitUsage
+	}
+
+	s, status := readTemplate(template, stderr)
+	if status != ExitOK {
+		return status
+	}
+
+	var list []string
+	if cats != "" {
+		list = strings.Split(cats, ",")
+	}
+
+	ledgers, alert := conti.AccountsLedger(s, list...)
+	if len(alert.Code) != 0 {
+		reportAlert(stderr, alert)
+		return ExitError
+	}
+	reportNotes(stderr, alert)
+
+	w := stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			fmt.Fprintln(stderr, "Writing error:", err)
+			return ExitError
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := conti.WriteLedgerCsv(w, ledgers); err != nil {
+		fmt.Fprintln(stderr, "Writing error:", err)
+		return ExitError
+	}
+	return ExitOK
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package cli for command-line interface (headless front end)
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/serdug/kitri/conti"
+	"github.com/serdug/kitri/handlers"
+)
+
+// roll runs the 'roll' command: reads a template, calculates accounts and
+// writes the chart files of the next period, a file per section with the
+// ending values as the starting balances, and the template of the next
+// period referring to them
+func roll(args []string, stdout, stderr io.Writer) int {
+	var (
+		output string
+		force  bool
+	)
+
+	fs := flag.NewFlagSet("roll", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&output, "o", "", "write the template of the next period to a `file`; chart files are written next to it")
+	fs.BoolVar(&force, "f", false, "overwrite existing files")
+
+	template, ok := parseArgs(fs, args)
+	if !ok {
+		return ExitUsage
+	}
+	if output == "" {
+		fmt.Fprintln(stderr, "No template of the next period provided, set it with -o")
+		return ExitUsage
+	}
+
+	s, status := readTemplate(template, stderr)
+	if status != ExitOK {
+		return status
+	}
+
+	cats, verdict, alert := conti.RollForward(s)
+	if len(alert.Code) != 0 {
+		reportAlert(stderr, alert)
+		return ExitError
+	}
+	reportNotes(stderr, alert)
+	if !verdict.Balanced {
+		fmt.Fprintln(stderr, "Warning:", verdict.Banner())
+		for _, d := range verdict.Imbalances {
+			fmt.Fprintln(stderr, "Warning:", d)
+		}
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(output))
+	if err != nil {
+		fmt.Fprintln(stderr, "Writing error:", err)
+		return ExitError
+	}
+	next := conti.NextSchema(s, dir+string(filepath.Separator))
+	sections := next.ChartSections()
+
+	// Note: refuse to overwrite files, e.g. the charts of the current period
+	// if the next period is rolled into the same directory
+	files := []string{output}
+	seen := map[string]string{}
+	for _, sect := range sections {
+		if other, ok := seen[sect.File]; ok {
+			fmt.Fprintf(stderr, "Sections '%s' and '%s' have the same chart file '%s'; rename a section\n", other, sect.Name, sect.File)
+			return ExitError
+		}
+		seen[sect.File] = sect.Name
+		files = append(files, filepath.Join(dir, sect.File))
+	}
+	if !force {
+		for _, f := range files {
+			if _, err := os.Stat(f); err == nil {
+				fmt.Fprintf(stderr, "File '%s' exists; roll forward into another directory or set -f to overwrite\n", f)
+				return ExitError
+			}
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Fprintln(stderr, "Writing error:", err)
+		return ExitError
+	}
+
+	for _, sect := range sections {
+		if err := writeChart(filepath.Join(dir, sect.File), cats, sect.Name); err != nil {
+			fmt.Fprintln(stderr, "Writing error:", err)
+			return ExitError
+		}
+	}
+
+	if err := handlers.WriteSchemaYAML(output, next); err != nil {
+		fmt.Fprintln(stderr, "Writing error:", err)
+		return ExitError
+	}
+
+	fmt.Fprintln(stdout, "Template of the next period saved to", output)
+	return ExitOK
+}
+
+// writeChart writes the categories of a section into a chart file
+func writeChart(filename string, cats []conti.Categories, section string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return conti.WriteChartCsv(f, cats, section)
+}
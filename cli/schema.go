@@ -0,0 +1,51 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package cli for command-line interface (headless front end)
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/serdug/kitri/conti"
+)
+
+// schema runs the 'schema' command: writes the JSON Schema of templates,
+// which editors may use to validate and complete templates
+func schema(args []string, stdout, stderr io.Writer) int {
+	var output string
+
+	fs := flag.NewFlagSet("schema", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&output, "o", "", "write the JSON Schema to a `file` instead of the standard output")
+
+	if err := fs.Parse(args); err != nil {
+		return ExitUsage
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintln(stderr, "Unexpected arguments:", strings.Join(fs.Args(), " "))
+		return ExitUsage
+	}
+
+	data, err := conti.TemplateJSONSchema()
+	if err != nil {
+		fmt.Fprintln(stderr, "Internal error:", err)
+		return ExitError
+	}
+	data = append(data, '\n')
+
+	if output == "" {
+		stdout.Write(data)
+		return ExitOK
+	}
+	if err := ioutil.WriteFile(output, data, 0644); err != nil {
+		fmt.Fprintln(stderr, "Writing error:", err)
+		return ExitError
+	}
+	return ExitOK
+}
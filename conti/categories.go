@@ -5,24 +5,20 @@
 // Package conti provides business logic of trial account calculation
 package conti
 
+import (
+	"fmt"
+	"strings"
+)
+
 // catVal builds a category-value map
-func catVal(cats []Categories) map[string]float64 {
-	cval := map[string]float64{}
+func catVal(cats []Categories) map[string]Money {
+	cval := map[string]Money{}
 	for i := range cats {
 		cval[cats[i].Cat] = cats[i].Bal.Dif
 	}
 	return cval
 }
 
-// staVal builds a category-value map of starting values
-func staVal(cats []Categories) map[string]float64 {
-	sval := map[string]float64{}
-	for i := range cats {
-		sval[cats[i].Cat] = cats[i].Bal.Sta // incorrect
-	}
-	return sval
-}
-
 // catSec builds a category-section map
 func catSec(cats []Categories) map[string]string {
 	csec := map[string]string{}
@@ -33,11 +29,111 @@ func catSec(cats []Categories) map[string]string {
 }
 
 // addBal modifies the balance value for the category
-func addBal(cval map[string]float64, cat string, val float64) map[string]float64 {
+func addBal(cval map[string]Money, cat string, val Money) map[string]Money {
 	if v, ok := cval[cat]; ok {
 		// Found
 		cval[cat] = v + val
-		return cval
 	}
-	return map[string]float64{}
+	// Note: unknown categories are rejected by validateTransactions before
+	// posting, so the map is returned unchanged
+	return cval
+}
+
+// checkCategories checks that the ids of categories are unique across the
+// sections of the Chart of Accounts. Blank ids, repeated ids and ids which
+// differ only in whitespace or leading zeros, e.g. '010' and ' 10', are
+// listed with the files and rows they are read from.
+func checkCategories(cats []Categories) NoticeOfError {
+	var (
+		alert NoticeOfError
+		files []string
+		lines []string
+		diags []Diagnostic
+	)
+
+	seen := map[string]bool{}
+	addFile := func(file string) {
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+
+	// Note: categories are kept by the id cleared of whitespace and leading
+	// zeros
+	first := map[string]Categories{}
+	for _, c := range cats {
+		if strings.TrimSpace(c.Cat) == "" {
+			lines = append(lines, fmt.Sprintf("%s: no category id", c.origin()))
+			diags = append(diags, c.diagnostic("no category id"))
+			addFile(c.File)
+			continue
+		}
+
+		key := catKey(c.Cat)
+		f, ok := first[key]
+		if !ok {
+			first[key] = c
+			continue
+		}
+
+		if f.Cat == c.Cat {
+			lines = append(lines, fmt.Sprintf("Category '%s' is repeated: %s (%s) and %s (%s)",
+				c.Cat, f.origin(), f.Sect, c.origin(), c.Sect))
+			diags = append(diags, c.diagnostic(fmt.Sprintf("category '%s' is repeated, see %s", c.Cat, f.origin())))
+		} else {
+			lines = append(lines, fmt.Sprintf("Categories '%s' and '%s' differ only in whitespace or leading zeros: %s (%s) and %s (%s)",
+				f.Cat, c.Cat, f.origin(), f.Sect, c.origin(), c.Sect))
+			diags = append(diags, c.diagnostic(fmt.Sprintf("category '%s' differs from '%s' only in whitespace or leading zeros, see %s", c.Cat, f.Cat, f.origin())))
+		}
+		addFile(f.File)
+		addFile(c.File)
+	}
+
+	if len(lines) == 0 {
+		return alert
+	}
+
+	alert = NoticeOfError{
+		Code:     CaseCategoryRepeated,
+		Resource: strings.Join(files, ", "),
+		Hint:     "Give every category of the Chart of Accounts an id of its own:\n" + strings.Join(lines, "\n"),
+		Err:      fmt.Errorf("%d blank or repeated category id(s)", len(lines)),
+
+		Diagnostics: diags,
+	}
+	alert.Trace.Crumbs("checkCategories")
+	return alert
+}
+
+// catKey clears a category id of whitespace and leading zeros, e.g. '10'
+// for ' 010'
+func catKey(cat string) string {
+	key := strings.Join(strings.Fields(cat), "")
+	if trimmed := strings.TrimLeft(key, "0"); trimmed != "" {
+		return trimmed
+	}
+	return "0"
+}
+
+// ***************************************************************************
+// * METHODS
+// ***************************************************************************
+
+// origin returns the file and the row the category is read from, e.g.
+// "file 'chart-assets.csv', row 4"
+func (c Categories) origin() string {
+	return fmt.Sprintf("file '%s', row %d", c.File, c.Row)
+}
+
+// diagnostic returns a diagnostic of an error in the id of the category
+func (c Categories) diagnostic(msg string) Diagnostic {
+	return Diagnostic{
+		Severity: SeverityError,
+		Code:     CaseCategoryRepeated,
+		File:     c.File,
+		Row:      c.Row,
+		Column:   c.col,
+		Message:  msg,
+	}
 }
@@ -0,0 +1,139 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package conti provides business logic of trial account calculation
+package conti
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Names of trial balance checks
+const (
+	CheckOpening     = "Opening balance"
+	CheckEnding      = "Balance sheet equation"
+	CheckRetained    = "Retained result"
+	CheckDoubleEntry = "Double entry"
+)
+
+// Imbalance describes an accounting identity that does not hold
+type Imbalance struct {
+	// Name of the check, e.g. CheckOpening
+	Check string
+
+	// Sections on the left-hand side of the identity
+	Left []string
+
+	// Sections on the right-hand side of the identity
+	Right []string
+
+	// The difference: left-hand side minus right-hand side
+	Amount Money
+}
+
+// Verdict represents the outcome of trial balance checks
+type Verdict struct {
+	// True if all identities hold
+	Balanced bool
+
+	// A list of identities that do not hold
+	Imbalances []Imbalance
+}
+
+// TrialBalance checks the accounting identities on section totals:
+// (1) the opening balance: Assets = Liabilities + Equity + Retained Result,
+// (2) the balance sheet equation for the ending values,
+// (3) the P&L profit (loss) equals the change in the retained result as
+// the Balance Sheet shows it: the change in the debit sections less the
+// change in the credit sections,
+// (4) double entry: the amounts debited equal the amounts credited, as
+// added up record by record on posting.
+// Sections are named as declared in the template: (1), (2) and (3) weigh
+// the debit sections of the Balance Sheet against the credit ones.
+func TrialBalance(rep Report) Verdict {
+	var (
+		v               Verdict
+		debits, credits []string
+	)
+
+	b := rep.Balance
+	p := rep.Profit
+
+	for _, g := range groups(rep.Sections, StatementBalance) {
+		if g.Side == SideDebit {
+			debits = append(debits, g.Name)
+		} else {
+			credits = append(credits, g.Name)
+		}
+	}
+
+	// Note: Balance.Retained is a copy of the P&L result made by finalBal,
+	// so the change in the retained result is taken from the other sections
+	// of the Balance Sheet; it differs from the P&L result if records cross
+	// into off-balance sections
+	retained := b.Assets.Dif - b.Liabls.Dif - b.Equity.Dif
+	changes := append([]string{}, debits...)
+	for _, c := range credits {
+		changes = append(changes, "less "+c)
+	}
+
+	credits = append(credits, "Retained Result")
+
+	v.compare(CheckOpening,
+		debits, b.Assets.Sta,
+		credits, b.Liabls.Sta+b.Equity.Sta+b.Retained.Sta)
+
+	v.compare(CheckEnding,
+		debits, b.Assets.End,
+		credits, b.Liabls.End+b.Equity.End+b.Retained.End)
+
+	v.compare(CheckRetained,
+		[]string{"Profit (Loss)"}, p.Profit.Dif,
+		[]string{"Change in " + strings.Join(changes, " ")}, retained)
+
+	v.compare(CheckDoubleEntry,
+		[]string{"Debits"}, rep.Debits,
+		[]string{"Credits"}, rep.Credits)
+
+	v.Balanced = len(v.Imbalances) == 0
+	return v
+}
+
+// ***************************************************************************
+// * METHODS
+// ***************************************************************************
+
+// compare registers an imbalance if the left-hand and the right-hand values
+// differ
+func (v *Verdict) compare(check string, left []string, lval Money, right []string, rval Money) {
+	if lval == rval {
+		return
+	}
+	v.Imbalances = append(v.Imbalances, Imbalance{
+		Check:  check,
+		Left:   left,
+		Right:  right,
+		Amount: lval - rval,
+	})
+}
+
+// Banner returns a short pass/fail line, e.g. for the top of an output
+func (v Verdict) Banner() string {
+	if v.Balanced {
+		return "Trial balance: PASS, the accounts are in balance"
+	}
+	return fmt.Sprintf("Trial balance: FAIL, %d check(s) out of balance", len(v.Imbalances))
+}
+
+// String describes the imbalance, e.g. 'Opening balance: Assets exceed
+// Liabilities + Equity + Retained Result by 10.00'
+func (d Imbalance) String() string {
+	verb, amount := "exceed", d.Amount
+	if amount < 0 {
+		verb, amount = "fall short of", -amount
+	}
+	return fmt.Sprintf("%s: %s %s %s by %s",
+		d.Check, strings.Join(d.Left, " + "), verb, strings.Join(d.Right, " + "), amount)
+}
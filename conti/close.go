@@ -0,0 +1,178 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package conti provides business logic of trial account calculation
+package conti
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// AccountsClose runs ending category calculations and generates the closing
+// records, which carry the ending values of the categories of the Profit &
+// Loss Statement into the category of retained earnings set in the template.
+// The closing records are posted on top of the calculated values to check
+// that the categories of the Profit & Loss Statement are zero after closing;
+// the closed categories and the totals per section are returned with the
+// records.
+func AccountsClose(q Schema) ([]Transactions, []Categories, Report, NoticeOfError) {
+	var alert NoticeOfError
+
+	target := strings.TrimSpace(q.Closing)
+	if target == "" {
+		alert = NoticeOfError{
+			Code: CaseNoData,
+			Hint: "Set the category of retained earnings to close the Profit & Loss Statement into, e.g. 'closing: 320', in the template",
+			Err:  errors.New("no closing category in the template"),
+		}
+		alert.Trace.Crumbs("AccountsClose")
+		return nil, nil, Report{}, alert
+	}
+
+	cats, rep, alert := AccountsReport(q)
+	if alert.Err != nil {
+		alert.Trace.Crumbs("AccountsClose")
+		return nil, nil, Report{}, alert
+	}
+
+	// Note: closing records are dated the last day of the reporting period
+	_, date, _ := q.Period.bounds()
+
+	notes := diagnostics(alert.Diagnostics)
+	recs, alert := closingRecords(cats, rep.Sections, target, date)
+	notes.add(alert)
+	if alert.Err != nil {
+		alert.Trace.Crumbs("AccountsClose")
+		return nil, nil, Report{}, notes.onto(alert)
+	}
+
+	closed, result, err := postTransactionsToAccounts(cats, recs, rep.Sections)
+	if err == nil {
+		err = checkClosed(closed, rep.Sections)
+	}
+	if err != nil {
+		alert = NoticeOfError{
+			Code: CaseInnerError,
+			Hint: "Send this error to the program developer",
+			Err:  err,
+		}
+		alert.Trace.Crumbs("AccountsClose")
+		return nil, nil, Report{}, notes.onto(alert)
+	}
+
+	return recs, closed, result, notes.onto(alert)
+}
+
+// closingRecords generates a record per category of the Profit & Loss
+// Statement with a non-zero ending value, carrying the value into the target
+// category. The target must be a category of the Balance Sheet.
+func closingRecords(cats []Categories, sections []Section, target string, date time.Time) ([]Transactions, NoticeOfError) {
+	var (
+		alert NoticeOfError
+		recs  []Transactions
+	)
+
+	statement := map[string]string{}
+	for _, s := range sections {
+		statement[s.Name] = s.Statement
+	}
+	sides := sectionSides(sections)
+
+	name, sect := "", ""
+	for _, c := range cats {
+		if c.Cat == target {
+			name, sect = c.Name, c.Sect
+		}
+	}
+
+	switch {
+	case sect == "":
+		alert = NoticeOfError{
+			Code: CaseCategoryNotKnown,
+			Hint: "Set the closing category in the template to a category of the Chart of Accounts",
+			Err:  fmt.Errorf("unknown closing category '%s'", target),
+		}
+		alert.Trace.Crumbs("closingRecords")
+		return recs, alert
+
+	case statement[sect] != StatementBalance:
+		alert = NoticeOfError{
+			Code: CaseWrongFormat,
+			Hint: "Set the closing category in the template to a category of the Balance Sheet, e.g. of Equity",
+			Err:  fmt.Errorf("closing category '%s' is in section '%s', which is not on the Balance Sheet", target, sect),
+		}
+		alert.Trace.Crumbs("closingRecords")
+		return recs, alert
+	}
+
+	for _, c := range cats {
+		if statement[c.Sect] != StatementProfit || c.Bal.End == 0 {
+			continue
+		}
+
+		// Note: a record from a category of the debit side takes its value
+		// off, as does a record to a category of the credit side
+		rec := Transactions{
+			Amount:  c.Bal.End,
+			Source:  c.Cat,
+			Purpose: target,
+			Date:    date,
+			Memo:    c.Name + " => " + name,
+		}
+		if sides[c.Sect] == SideCredit {
+			rec.Source, rec.Purpose = rec.Purpose, rec.Source
+		}
+		if rec.Amount < 0 {
+			rec.Amount = -rec.Amount
+			rec.Source, rec.Purpose = rec.Purpose, rec.Source
+		}
+		recs = append(recs, rec)
+	}
+	return recs, alert
+}
+
+// checkClosed checks that the ending values of the categories of the Profit
+// & Loss Statement are zero
+func checkClosed(cats []Categories, sections []Section) error {
+	var open []string
+
+	statement := map[string]string{}
+	for _, s := range sections {
+		statement[s.Name] = s.Statement
+	}
+
+	for _, c := range cats {
+		if statement[c.Sect] == StatementProfit && c.Bal.End != 0 {
+			open = append(open, fmt.Sprintf("'%s' (%s)", c.Cat, c.Bal.End))
+		}
+	}
+	if len(open) != 0 {
+		return errors.New("categories not closed: " + strings.Join(open, ", "))
+	}
+	return nil
+}
+
+// WriteRecordsCsv writes records of transactions in the CSV format to w,
+// in the default column order: amount, source, purpose, date, followed by
+// the description
+func WriteRecordsCsv(w io.Writer, recs []Transactions) error {
+	writer := csv.NewWriter(w)
+
+	writer.Write([]string{"Amount", "Source", "Purpose", "Date", "Description"})
+	for _, rec := range recs {
+		date := ""
+		if !rec.Date.IsZero() {
+			date = rec.Date.Format("2006-01-02")
+		}
+		writer.Write([]string{rec.Amount.String(), rec.Source, rec.Purpose, date, rec.Memo})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,234 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package conti provides business logic of trial account calculation
+package conti
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// noColumn marks a data field that is not read from a file
+const noColumn = -1
+
+// index returns the 0-based index of the column: either the one set by
+// its 1-based number or title, or the default one
+func (c Column) index(head []string, def int) (int, error) {
+	key := strings.TrimSpace(string(c))
+	if key == "" {
+		return def, nil
+	}
+
+	if n, err := strconv.Atoi(key); err == nil {
+		if n < 1 {
+			return noColumn, errors.New("column number " + key + " is out of range, columns are numbered from 1")
+		}
+		return n - 1, nil
+	}
+
+	for i, title := range head {
+		if strings.EqualFold(strings.TrimSpace(title), key) {
+			return i, nil
+		}
+	}
+	return noColumn, errors.New("column '" + key + "' is not found in the title row")
+}
+
+// cell returns the value of a cell in a row, or an empty string if there is
+// no such column
+func cell(row []string, col int) string {
+	if col < 0 || col >= len(row) {
+		return ""
+	}
+	return row[col]
+}
+
+// blankRow reports whether all cells of a row are empty, e.g. a spacer row
+// in a sheet
+func blankRow(row []string) bool {
+	for _, v := range row {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// recordColumns resolves the columns of a records file
+// Note: the default layout is Amount, Source, Purpose and Date in
+// columns 1 to 4, and optional Description and Ref columns found by their
+// titles
+func (c Columns) recordColumns(head []string) (cols recordCols, err error) {
+	for _, one := range []struct {
+		col *int
+		key Column
+		def int
+	}{
+		{&cols.amount, c.Amount, 0},
+		{&cols.source, c.Source, 1},
+		{&cols.purpose, c.Purpose, 2},
+		{&cols.date, c.Date, 3},
+		{&cols.memo, c.Memo, titled(head, "Description")},
+		{&cols.ref, c.Ref, titled(head, "Ref")},
+	} {
+		*one.col, err = one.key.index(head, one.def)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// chartColumns resolves the columns of a Chart of Accounts file
+// Note: the default layout is Category, Name and Balance in columns 1 to 3,
+// and an optional Parent column found by its title
+func (c Columns) chartColumns(head []string) (cols chartCols, err error) {
+	for _, one := range []struct {
+		col *int
+		key Column
+		def int
+	}{
+		{&cols.cat, c.Cat, 0},
+		{&cols.name, c.Name, 1},
+		{&cols.balance, c.Balance, 2},
+		{&cols.parent, c.Parent, titled(head, "Parent")},
+	} {
+		*one.col, err = one.key.index(head, one.def)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// titled returns the 0-based index of the column with the title, or
+// noColumn if there is none
+func titled(head []string, title string) int {
+	for i, each := range head {
+		if strings.EqualFold(strings.TrimSpace(each), title) {
+			return i
+		}
+	}
+	return noColumn
+}
+
+// recordCols holds 0-based indices of columns in a records file
+type recordCols struct {
+	amount, source, purpose, date, memo, ref int
+}
+
+// read returns the columns read into the data fields of records
+func (cols recordCols) read() []int {
+	return []int{cols.amount, cols.source, cols.purpose, cols.date, cols.memo, cols.ref}
+}
+
+// journalColumns resolves the columns of a journal file
+// Note: the default layout is Entry, Date, Account, Debit and Credit in
+// columns 1 to 5, and optional Description and Ref columns found by their
+// titles
+func (c Columns) journalColumns(head []string) (cols journalCols, err error) {
+	for _, one := range []struct {
+		col *int
+		key Column
+		def int
+	}{
+		{&cols.entry, c.Entry, 0},
+		{&cols.date, c.Date, 1},
+		{&cols.account, c.Account, 2},
+		{&cols.debit, c.Debit, 3},
+		{&cols.credit, c.Credit, 4},
+		{&cols.memo, c.Memo, titled(head, "Description")},
+		{&cols.ref, c.Ref, titled(head, "Ref")},
+	} {
+		*one.col, err = one.key.index(head, one.def)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// journalCols holds 0-based indices of columns in a journal file
+type journalCols struct {
+	entry, date, account, debit, credit, memo, ref int
+}
+
+// read returns the columns read into the data fields of journal legs
+func (cols journalCols) read() []int {
+	return []int{cols.entry, cols.date, cols.account, cols.debit, cols.credit, cols.memo, cols.ref}
+}
+
+// extraTitles names the columns of a file not read into data fields by
+// their titles; an empty name marks a column that is read. Untitled
+// columns and repeated titles are named by their numbers, e.g. 'Column 7'.
+func extraTitles(head []string, read []int) []string {
+	used := map[int]bool{}
+	for _, col := range read {
+		used[col] = true
+	}
+
+	names := make([]string, len(head))
+	seen := map[string]bool{}
+	for i, title := range head {
+		if used[i] {
+			continue
+		}
+		name := strings.TrimSpace(title)
+		if name == "" || seen[strings.ToLower(name)] {
+			name = columnName(i)
+		}
+		seen[strings.ToLower(name)] = true
+		names[i] = name
+	}
+	return names
+}
+
+// metadata returns the cells of the columns not read into data fields by
+// the names of the columns, or nil if there are none; cells beyond the
+// title row are named by their numbers
+func metadata(names []string, read []int, row []string) map[string]string {
+	var meta map[string]string
+
+	for i, v := range row {
+		name := ""
+		switch {
+		case i < len(names):
+			name = names[i]
+		case !hasColumn(read, i):
+			name = columnName(i)
+		}
+		if name == "" {
+			// The column is read
+			continue
+		}
+
+		if meta == nil {
+			meta = map[string]string{}
+		}
+		meta[name] = strings.TrimSpace(v)
+	}
+	return meta
+}
+
+// hasColumn detects whether the column is in the list
+func hasColumn(cols []int, col int) bool {
+	for _, c := range cols {
+		if c == col {
+			return true
+		}
+	}
+	return false
+}
+
+// columnName names a column by its 0-based index, e.g. 'Column 7'
+func columnName(i int) string {
+	return "Column " + strconv.Itoa(i+1)
+}
+
+// chartCols holds 0-based indices of columns in a Chart of Accounts file
+type chartCols struct {
+	cat, name, balance, parent int
+}
@@ -5,48 +5,129 @@
 // Package conti provides business logic of trial account calculation
 package conti
 
-import (
-	"fmt"
-)
-
-// Accounts runs ending category [and Balance and P/L] calculations
-// based on the records passed in CSV data files.
+// Accounts runs ending category calculations based on the records passed
+// in CSV data files.
 func Accounts(q Schema) ([]Categories, NoticeOfError) {
+	cats, _, alert := AccountsReport(q)
+	return cats, alert
+}
+
+// AccountsReport runs ending category calculations based on the records
+// passed in CSV data files and returns the categories together with the
+// Balance and P/L totals per section.
+func AccountsReport(q Schema) ([]Categories, Report, NoticeOfError) {
+	b, alert := openBooks(q)
+	if alert.Err != nil {
+		alert.Trace.Crumbs("AccountsReport")
+		return nil, Report{}, alert
+	}
+
+	conti, result, err := postTransactionsToAccounts(b.cats, b.records, b.sections)
+	if err != nil {
+		alert = NoticeOfError{
+			Code: CaseInnerError,
+			Hint: "Send this error to the program developer",
+			Err:  err,
+
+			Diagnostics: alert.Diagnostics,
+		}
+		alert.Trace.Crumbs("postTransactionsToAccounts")
+		return nil, Report{}, alert
+	}
+
+	// fmt.Println("Categories in results:", len(conti))
+
+	return conti, result, alert
+}
+
+// books keeps categories with their opening values and the records of the
+// reporting period to be posted to them
+type books struct {
+	cats     []Categories
+	records  []Transactions
+	sections []Section
+}
+
+// openBooks reads and checks the Chart of Accounts and the records, and
+// carries the records dated before the reporting period into the opening
+// values of categories
+func openBooks(q Schema) (books, NoticeOfError) {
 	var (
 		alert NoticeOfError
 		cats  []Categories
 		recs  []Transactions
+		notes diagnostics
 	)
 
+	// Refuse wrong settings of sections of the Chart of Accounts
+	alert = checkSections(q)
+	notes.add(alert)
+	if alert.Err != nil {
+		alert.Trace.Crumbs("openBooks")
+		return books{}, notes.onto(alert)
+	}
+	sections := q.ChartSections()
+
 	// Note: const Headers bool = true
 	cats, alert = gatherCategories(q, Headers)
-	if alert.Error != nil {
-		alert.Trace.Crumbs("Accounts")
-		fmt.Printf("Trail (%v): %v\n", len(alert.Trace.x), alert.Trace)
-		return nil, alert
+	notes.add(alert)
+	if alert.Err != nil {
+		alert.Trace.Crumbs("openBooks")
+		return books{}, notes.onto(alert)
 	}
 	// fmt.Println("Total categories read:", len(cats))
 
+	// Refuse blank and repeated ids of categories
+	alert = checkCategories(cats)
+	notes.add(alert)
+	if alert.Err != nil {
+		alert.Trace.Crumbs("openBooks")
+		return books{}, notes.onto(alert)
+	}
+
+	// Find parents of categories for roll-ups
+	cats, alert = linkCategories(cats, q.Hierarchy)
+	notes.add(alert)
+	if alert.Err != nil {
+		alert.Trace.Crumbs("openBooks")
+		return books{}, notes.onto(alert)
+	}
+
 	recs, alert = gatherTransactions(q, Headers)
-	if alert.Error != nil {
-		alert.Trace.Crumbs("Accounts")
-		fmt.Printf("Trail (%v): %v\n", len(alert.Trace.x), alert.Trace)
-		return nil, alert
+	notes.add(alert)
+	if alert.Err != nil {
+		alert.Trace.Crumbs("openBooks")
+		return books{}, notes.onto(alert)
 	}
 	// fmt.Println("Total records read:", len(recs))
 
-	conti, err := postTransactionsToAccounts(cats, recs)
+	// Refuse to post records referring to unknown categories
+	alert = validateTransactions(cats, recs)
+	notes.add(alert)
+	if alert.Err != nil {
+		alert.Trace.Crumbs("openBooks")
+		return books{}, notes.onto(alert)
+	}
+
+	// Carry records dated before the reporting period into the opening
+	// balances and leave out records after the period
+	prior, recs, alert := q.Period.split(recs)
+	notes.add(alert)
+	if alert.Err != nil {
+		alert.Trace.Crumbs("openBooks")
+		return books{}, notes.onto(alert)
+	}
+
+	cats, err := carryForward(cats, prior, sections)
 	if err != nil {
 		alert = NoticeOfError{
-			Code:  CaseInnerError,
-			Hint:  "Send this error to the program developer",
-			Error: err,
+			Code: CaseInnerError,
+			Hint: "Send this error to the program developer",
+			Err:  err,
 		}
-		alert.Trace.Crumbs("postTransactionsToAccounts")
-		return nil, alert
+		alert.Trace.Crumbs("carryForward")
+		return books{}, notes.onto(alert)
 	}
 
-	// fmt.Println("Categories in results:", len(conti))
-
-	return conti, alert
+	return books{cats: cats, records: recs, sections: sections}, notes.onto(alert)
 }
@@ -7,9 +7,16 @@ package conti
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings" // to split strings
+
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
 )
 
 var (
@@ -18,83 +25,306 @@ var (
 
 // TO DO: TRACE errors!!!
 // ExportAccountsToCsv writes results of value-by-category
-// calculations in a CSV file
-func ExportAccountsToCsv(conti []Categories, filename string) {
-	var (
-		err1  error
-		field []string
-	)
+// calculations in a CSV file, and the trial balance verdict with the
+// statements in a file of its own named by StatementsFile
+func ExportAccountsToCsv(conti []Categories, rep Report, depth int, filename string) {
+	exportCsv(filename, func(w io.Writer) error { return WriteAccountsCsv(w, conti, rep, depth) })
+	exportCsv(StatementsFile(filename), func(w io.Writer) error { return WriteStatementsCsv(w, conti, rep, depth) })
+}
 
-	csvNewFile, err := os.OpenFile(filename, os.O_RDWR, 0666) // os.O_RDONLY, os.O_WRONLY, os.O_RDWR
-	//csvNewFile, err := os.Open(filename) // READ-ONLY!!!
+// exportCsv creates a file and writes it with write
+func exportCsv(filename string, write func(w io.Writer) error) {
+	csvNewFile, err := os.Create(filename)
 	if err != nil {
-		//fmt.Println("Open error:", err)
-		csvNewFile, err1 = os.Create(filename)
-		if err1 != nil {
-			fmt.Printf("Error (creating file %s): %s\n", filename, err1)
-		}
+		fmt.Fprintf(os.Stderr, "Error (creating file %s): %s\n", filename, err)
+		return
 	}
 	defer csvNewFile.Close()
 
-	writer := csv.NewWriter(csvNewFile)
+	err = write(csvNewFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error (writing file %s): %s\n", filename, err)
+	}
+}
+
+// StatementsFile returns the name of the CSV file of statements saved next
+// to a CSV file of categories, e.g. 'results-statements.csv' for
+// 'results.csv'
+func StatementsFile(filename string) string {
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + "-statements" + ext
+}
+
+// WriteAccountsCsv writes results of value-by-category calculations in the
+// CSV format to w, e.g. to a file or to the standard output, as a single
+// table with a title row, so that it may be read by other tools.
+// Categories are rolled up and shown to depth levels of the hierarchy, all
+// levels if depth is 0; names are indented by level.
+func WriteAccountsCsv(w io.Writer, conti []Categories, rep Report, depth int) error {
+	var field []string
+
+	writer := csv.NewWriter(w)
 
 	headers := strings.Split(attributes, "+")
 	writer.Write(headers)
 
-	for _, one := range conti {
+	for _, one := range Collapse(RollUp(conti), depth) {
 		field = make([]string, len(headers))
 
 		field[0] = one.Cat
 		field[1] = one.Sect
-		field[2] = one.Name
+		field[2] = indent(one.Level) + one.Name
 		// Converting response to a single string
-		field[3] = fmt.Sprintf("%f", one.Bal.Sta)
-		field[4] = fmt.Sprintf("%f", one.Bal.Dif)
-		field[5] = fmt.Sprintf("%f", one.Bal.End)
+		field[3] = one.Bal.Sta.String()
+		field[4] = one.Bal.Dif.String()
+		field[5] = one.Bal.End.String()
 
 		writer.Write(field)
 	}
 
 	// remember to flush!
 	writer.Flush()
-	return
+	return writer.Error()
+}
+
+// WriteStatementsCsv writes the trial balance verdict banner followed by
+// imbalances, if any, the Balance Sheet and the Profit & Loss Statement in
+// the CSV format to w; blocks are separated by empty rows. Categories are
+// shown to depth levels of the hierarchy, all levels if depth is 0.
+func WriteStatementsCsv(w io.Writer, conti []Categories, rep Report, depth int) error {
+	writer := csv.NewWriter(w)
+
+	verdict := TrialBalance(rep)
+	writer.Write([]string{verdict.Banner()})
+	for _, d := range verdict.Imbalances {
+		writer.Write([]string{d.String()})
+	}
+
+	for _, stmt := range Statements(conti, rep, depth) {
+		writer.Write([]string{})
+		writeStatementCsv(writer, stmt)
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeStatementCsv writes a statement: the title, column titles and lines
+func writeStatementCsv(writer *csv.Writer, stmt Statement) {
+	writer.Write([]string{stmt.Title})
+	writer.Write([]string{"Cat", "Name", "Starting", "Change", "Ending"})
+
+	for _, line := range stmt.Lines {
+		if line.Kind == LineHeading {
+			writer.Write([]string{line.Name})
+			continue
+		}
+		writer.Write([]string{
+			line.Cat,
+			indent(line.Level) + line.Name,
+			line.Value.Sta.String(),
+			line.Value.Dif.String(),
+			line.Value.End.String(),
+		})
+	}
 }
 
-// readFileCsv reads data from a csv file into a [][]string matrix
-func readFileCsv(filename string) ([][]string, NoticeOfError) {
+// readFileCsv reads data from a csv file written in the dialect d into
+// a [][]string matrix
+func readFileCsv(filename string, d Dialect) ([][]string, NoticeOfError) {
 	var (
 		alert NoticeOfError
 		mx    [][]string
 	)
 
-	f, errOpen := os.Open(filename)
+	dat, errOpen := ioutil.ReadFile(filename)
 	if errOpen != nil {
 		alert = NoticeOfError{
 			Code:     CaseNotFound,
 			Resource: filename,
 			Hint:     "File not found: " + filename,
-			Error:    errOpen,
+			Err:      errOpen,
+		}
+		if !os.IsNotExist(errOpen) {
+			alert.Code = CaseUnreadable
+			alert.Hint = "Failed to open: " + filename
 		}
 		alert.Trace.Crumbs("readFileCsv")
 		return mx, alert
 	}
 
-	defer f.Close()
-
-	reader := csv.NewReader(f)
-	reader.FieldsPerRecord = -1
+	text, errDecode := d.decode(dat)
+	if errDecode != nil {
+		alert = NoticeOfError{
+			Code:     CaseWrongFormat,
+			Resource: filename,
+			Hint:     "Check the text encoding set for the file in the template",
+			Err:      errDecode,
+		}
+		alert.Trace.Crumbs("readFileCsv")
+		return mx, alert
+	}
 
-	mx, errRead := reader.ReadAll()
-	if errRead != nil {
+	reader, quote, errDialect := d.reader(text)
+	if errDialect != nil {
 		alert = NoticeOfError{
-			Code:     CaseUnreadable,
+			Code:     CaseWrongFormat,
 			Resource: filename,
-			Hint:     "Failed to read: " + filename,
-			Error:    errRead,
+			Hint:     "Check the CSV dialect set for the file in the template",
+			Err:      errDialect,
 		}
 		alert.Trace.Crumbs("readFileCsv")
-		os.Exit(1)
 		return mx, alert
 	}
+
+	// Note: the reader skips blank and comment lines, which are rows of a
+	// spreadsheet; empty rows are put in their place to keep the row numbers
+	lines := 0
+	for {
+		each, errRead := reader.Read()
+		if errRead == io.EOF {
+			break
+		}
+		if errRead != nil {
+			alert = NoticeOfError{
+				Code:     CaseUnreadable,
+				Resource: filename,
+				Hint:     "Failed to read: " + filename + "; check the delimiter and quotes",
+				Err:      errRead,
+			}
+			alert.Trace.Crumbs("readFileCsv")
+			return mx, alert
+		}
+
+		start, _ := reader.FieldPos(0)
+		for ; lines < start-1; lines++ {
+			mx = append(mx, []string{})
+		}
+		mx = append(mx, each)
+
+		// A quoted field may span lines
+		lines = start + strings.Count(strings.Join(each, ""), "\n")
+	}
+
+	// Restore quote characters swapped for reading
+	if quote != '"' {
+		for i := range mx {
+			for j := range mx[i] {
+				mx[i][j] = swapQuote(mx[i][j], quote)
+			}
+		}
+	}
 	return mx, alert
 }
+
+// decode converts the contents of a file into UTF-8 text and strips the
+// byte order mark unless it should be kept
+func (d Dialect) decode(dat []byte) (string, error) {
+	name := strings.TrimSpace(d.Encoding)
+
+	if name != "" && !strings.EqualFold(name, "utf-8") && !strings.EqualFold(name, "utf8") {
+		enc, err := ianaindex.IANA.Encoding(name)
+		if err != nil || enc == nil {
+			enc, err = htmlindex.Get(name)
+		}
+		if err != nil || enc == nil {
+			return "", errors.New("unknown text encoding '" + name + "'")
+		}
+
+		dat, err = enc.NewDecoder().Bytes(dat)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	text := string(dat)
+	if !d.KeepBOM {
+		text = strings.TrimPrefix(text, "\ufeff")
+	}
+	return text, nil
+}
+
+// reader creates a CSV reader for the dialect. Quotes other than '"' are
+// swapped with '"' in the text; the returned quote character is to be
+// swapped back in the fields read.
+func (d Dialect) reader(text string) (*csv.Reader, rune, error) {
+	quote := '"'
+	switch q := []rune(d.Quote); {
+	case len(q) == 0:
+		// Standard double quote
+	case strings.EqualFold(d.Quote, "none"):
+		// Note: quotes are ordinary text, hide them from the CSV reader
+		quote = 0
+	case len(q) == 1:
+		quote = q[0]
+	default:
+		return nil, quote, errors.New("quote '" + d.Quote + "' must be a single character or 'none'")
+	}
+
+	if quote != '"' {
+		text = swapQuote(text, quote)
+	}
+
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.FieldsPerRecord = -1
+
+	switch r := []rune(d.Delimiter); {
+	case len(r) == 0:
+		// Comma
+	case strings.EqualFold(d.Delimiter, "tab"):
+		reader.Comma = '\t'
+	case len(r) == 1:
+		reader.Comma = r[0]
+	default:
+		return nil, quote, errors.New("delimiter '" + d.Delimiter + "' must be a single character or 'tab'")
+	}
+
+	switch r := []rune(d.Comment); {
+	case len(r) == 0:
+		// No comments
+	case len(r) == 1:
+		reader.Comment = r[0]
+	default:
+		return nil, quote, errors.New("comment '" + d.Comment + "' must be a single character")
+	}
+
+	if reader.Comma == reader.Comment || reader.Comma == quote {
+		return nil, quote, errors.New("delimiter, quote and comment characters must differ")
+	}
+
+	return reader, quote, nil
+}
+
+// headerRows returns the number of title rows to skip: as set in the
+// dialect or, by default, one if headers is true
+func (d Dialect) headerRows(headers bool) int {
+	switch {
+	case d.Headers != nil && *d.Headers > 0:
+		return *d.Headers
+	case d.Headers != nil:
+		return 0
+	case headers:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// swapQuote swaps a quote character with the standard double quote
+func swapQuote(s string, quote rune) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case quote:
+			return '"'
+		case '"':
+			return quote
+		default:
+			return r
+		}
+	}, s)
+}
+
+// indent returns spaces to indent a name by the level of the hierarchy
+func indent(level int) string {
+	return strings.Repeat("  ", level)
+}
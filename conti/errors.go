@@ -7,6 +7,8 @@ package conti
 
 import (
 	"fmt"
+	"os"
+	"strings"
 )
 
 // Status codes
@@ -14,13 +16,29 @@ const (
 	CaseNotFound         = "Resource not found"
 	CaseUnreadable       = "Unexpected type of or damaged file"
 	CaseCategoryNotKnown = "Unrecognizable category"
+	CaseCategoryRepeated = "Ambiguous category"
 	CaseNoData           = "No data provided"
 	CaseUnnamedFile      = "No file name"
 	CaseWrongFileType    = "Wrong file type"
 	CaseWrongFormat      = "Wrong data format"
+	CaseUnbalancedEntry  = "Unbalanced journal entry"
+	CaseUnknownSetting   = "Unknown setting"
+	CaseMissingSetting   = "Required setting missing"
 	CaseInnerError       = "Internal program error"
 )
 
+// Severities of diagnostics
+const (
+	// The problem stops calculation
+	SeverityError = "error"
+
+	// Calculation goes on, but the results may differ from those expected
+	SeverityWarning = "warning"
+
+	// A note on how the input is read
+	SeverityInfo = "info"
+)
+
 // NoticeOfError provides a structure for user guidance if calculation has gone not as
 // expected
 type NoticeOfError struct {
@@ -43,7 +61,31 @@ type NoticeOfError struct {
 	Hint string
 
 	// Standard error message
-	Error error
+	Err error
+
+	// Problems found in the input on the way, including those which don't
+	// stop calculation; they may come with no error
+	Diagnostics []Diagnostic
+}
+
+// Diagnostic describes a problem found in the input, located as exactly as
+// known: by the file, the row and the column
+type Diagnostic struct {
+	// Severity: 'error', 'warning' or 'info'
+	Severity string
+
+	// A code of the problem, e.g. CaseWrongFormat
+	Code string
+
+	// The name of the file, the number of the row (1-based, title rows
+	// included) and the number of the column (1-based); empty or zero if
+	// not known
+	File   string
+	Row    int
+	Column int
+
+	// What is wrong and how to fix it
+	Message string
 }
 
 // Trail represents an array of marks
@@ -51,10 +93,47 @@ type Trail struct {
 	x []string
 }
 
+// diagnostics keeps diagnostics of the steps of calculation in the order of
+// the steps
+type diagnostics []Diagnostic
+
 // warning prints an error message; it does not cause a process to end.
 func warning(msg string, e error) {
 	if e != nil {
-		fmt.Printf("%s [%v]\n", msg, e)
+		fmt.Fprintf(os.Stderr, "%s [%v]\n", msg, e)
+	}
+}
+
+// badCell returns a diagnostic of an error in a cell of a file; col is the
+// 0-based index of the column
+func badCell(file string, row, col int, msg string) Diagnostic {
+	return Diagnostic{
+		Severity: SeverityError,
+		Code:     CaseWrongFormat,
+		File:     file,
+		Row:      row,
+		Column:   col + 1,
+		Message:  msg,
+	}
+}
+
+// wrongCells returns a notice of errors in cells of a file listing them all,
+// or no notice if there are none
+func wrongCells(file string, diags []Diagnostic) NoticeOfError {
+	if len(diags) == 0 {
+		return NoticeOfError{}
+	}
+
+	lines := make([]string, len(diags))
+	for i, d := range diags {
+		lines[i] = fmt.Sprintf("Row %d, column %d: %s", d.Row, d.Column, d.Message)
+	}
+	return NoticeOfError{
+		Code:        CaseWrongFormat,
+		Resource:    file,
+		Hint:        "Correct the values in '" + file + "', or the number format set for it in the template:\n" + strings.Join(lines, "\n"),
+		Err:         fmt.Errorf("%d wrong value(s) in '%s'", len(diags), file),
+		Diagnostics: diags,
 	}
 }
 
@@ -66,3 +145,78 @@ func warning(msg string, e error) {
 func (t *Trail) Crumbs(mark string) {
 	t.x = append(t.x, mark)
 }
+
+// Marks returns the marks of execution, from the process that owns the
+// fault to the one called by the client
+func (t Trail) Marks() []string {
+	return append([]string(nil), t.x...)
+}
+
+// String joins the marks of execution, e.g. 'mx2cats < gatherCategories'
+func (t Trail) String() string {
+	return strings.Join(t.x, " < ")
+}
+
+// Error returns the code of the state of calculation with the standard
+// error message, so that a notice may be returned as an error
+func (this NoticeOfError) Error() string {
+	msg := this.Code
+	if this.Err != nil {
+		if msg != "" {
+			msg += ": "
+		}
+		msg += this.Err.Error()
+	}
+	if this.Resource != "" {
+		msg += " (" + this.Resource + ")"
+	}
+	return msg
+}
+
+// Unwrap returns the standard error, for errors.Is and errors.As
+func (this NoticeOfError) Unwrap() error {
+	return this.Err
+}
+
+// Count returns the number of diagnostics of the severity
+func (this NoticeOfError) Count(severity string) int {
+	n := 0
+	for _, d := range this.Diagnostics {
+		if d.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+// String describes the problem with its location, e.g.
+// "warning: file 'main-fees.csv', row 4, column 1: ..."
+func (d Diagnostic) String() string {
+	var at []string
+	if d.File != "" {
+		at = append(at, "file '"+d.File+"'")
+	}
+	if d.Row > 0 {
+		at = append(at, fmt.Sprintf("row %d", d.Row))
+	}
+	if d.Column > 0 {
+		at = append(at, fmt.Sprintf("column %d", d.Column))
+	}
+
+	if len(at) == 0 {
+		return d.Severity + ": " + d.Message
+	}
+	return d.Severity + ": " + strings.Join(at, ", ") + ": " + d.Message
+}
+
+// add keeps the diagnostics of a step
+func (this *diagnostics) add(alert NoticeOfError) {
+	*this = append(*this, alert.Diagnostics...)
+}
+
+// onto returns the notice with the diagnostics kept so far in place of its
+// own, which must have been added
+func (this diagnostics) onto(alert NoticeOfError) NoticeOfError {
+	alert.Diagnostics = append([]Diagnostic(nil), this...)
+	return alert
+}
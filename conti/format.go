@@ -6,11 +6,13 @@
 package conti
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"sort"
-	"strconv"
 	"strings"
+	"time"
 )
 
 // Categories represents The Chart of Accounts, a sctructure of accounts.
@@ -24,21 +26,35 @@ type Categories struct {
 	// Category name
 	Name string
 
+	// Optional id of the parent category, e.g. a group of accounts
+	Parent string
+
+	// Depth of the category in the hierarchy, 0 for top categories
+	Level int
+
 	// Balance value, e.g. opening (starting) or closing balance
 	// Note: it's optional for revenue and expense accounts
 	Bal Tally
+
+	// The name of the file the category is read from and the number of the
+	// row in the file (1-based, title rows included)
+	File string
+	Row  int
+
+	// The column of the id (1-based)
+	col int
 }
 
 type Tally struct {
-	Sta float64 // Starting
-	Dif float64 // Change
-	End float64 // Ending
+	Sta Money // Starting
+	Dif Money // Change
+	End Money // Ending
 }
 
 // Transactions for accounting book entries
 type Transactions struct {
 	// Amount
-	Amount float64
+	Amount Money
 
 	// The source of funds, i.e. an id of account (category) from which money is
 	// spent (paid / invested / lent) or received (earned / borrowed)
@@ -47,31 +63,63 @@ type Transactions struct {
 	// The purpose (or reason) for paying for smth or the use of receipts,
 	// i.e. an id of account (category) to which the money is purposed
 	Purpose string
+
+	// The date of transaction; zero if not provided
+	Date time.Time
+
+	// Optional description of transaction
+	Memo string
+
+	// Optional reference, e.g. an invoice number
+	Ref string
+
+	// The name of the file the record is read from
+	File string
+
+	// The number of the row in the file (1-based, title rows included)
+	Row int
+
+	// The row as read from the file
+	Raw []string
+
+	// The other columns of the row by their titles, e.g. 'Supplier'; nil if
+	// the file has no other columns
+	Meta map[string]string
+
+	// The legs of the journal entry the record is decomposed from, if any
+	legs *legPair
+
+	// The columns of the source and of the purpose (1-based)
+	cols [2]int
 }
 
-// gatherTransactions reads records from CSV data files into a slice of
-// Transactions objects. No data validation.
+// gatherTransactions reads records from CSV data files and sheets of
+// workbooks into a slice of Transactions objects. No data validation.
 func gatherTransactions(q Schema, headers bool) ([]Transactions, NoticeOfError) {
 	var (
 		file      string
 		alert     NoticeOfError
 		rec, recs []Transactions
+		head      []string
 		raw       [][]string
+		first     int
+		notes     diagnostics
 	)
 
 	for _, record := range q.Records {
-		// Exclude files if Include == 0
-		if record.Include == 0 {
+		// Exclude files left out of the calculation
+		if !record.Include {
 			// Go to the next iteration: skip record, don't count this file
 			continue
 		}
 
 		// Exclude unnamed files
 		file, alert = fileType(record.Id)
+		notes.add(alert)
 
-		if alert.Error != nil {
+		if alert.Err != nil {
 			alert.Trace.Crumbs("gatherTransactions")
-			return recs, alert
+			return recs, notes.onto(alert)
 		}
 
 		if alert.Code == CaseUnnamedFile {
@@ -81,77 +129,88 @@ func gatherTransactions(q Schema, headers bool) ([]Transactions, NoticeOfError)
 			continue
 		}
 
-		raw, alert = file2mx(filepath.Join(q.Path, file), headers)
+		layout := q.layout(record.Layout, file)
+		skip := layout.Dialect.headerRows(headers)
+
+		head, raw, first, alert = file2mx(filepath.Join(q.Path, file), layout.Dialect, skip)
 		// Note: Alternatively, use Join() from path/filepath
-		// raw, alert = file2mx(q.Path + file, headers)
-		if alert.Error != nil {
+		// head, raw, first, alert = file2mx(q.Path + file, layout.Dialect, skip)
+		notes.add(alert)
+		if alert.Err != nil {
 			alert.Trace.Crumbs("gatherTransactions")
-			return recs, alert
+			return recs, notes.onto(alert)
 		}
 
-		rec, alert = readTransactions(raw)
-		if alert.Error != nil {
+		switch strings.ToLower(strings.TrimSpace(record.Format)) {
+		case "":
+			rec, alert = readTransactions(head, raw, layout, file, first)
+		case FormatJournal:
+			rec, alert = readJournal(head, raw, layout, file, first)
+		default:
+			alert = NoticeOfError{
+				Code:     CaseWrongFormat,
+				Resource: file,
+				Err:      errors.New("unknown format '" + record.Format + "'"),
+				Hint:     "Set the format of '" + file + "' in the template to '" + FormatJournal + "', or leave it out for records of a source and a purpose",
+			}
+		}
+		notes.add(alert)
+		if alert.Err != nil {
 			alert.Trace.Crumbs("gatherTransactions")
-			return recs, alert
+			return recs, notes.onto(alert)
 		}
 
 		recs = append(recs, rec...)
 	}
 
-	return recs, alert
+	return recs, notes.onto(alert)
 }
 
-// gatherCategories reads records from CSV data files (arranged by preset
-// Sections) into a slice of Transactions objects containing Sections.
-// No data validation.
+// gatherCategories reads records from CSV data files and sheets of
+// workbooks (arranged by preset Sections) into a slice of Transactions
+// objects containing Sections. No data validation.
 func gatherCategories(q Schema, headers bool) ([]Categories, NoticeOfError) {
 	var (
 		file  string
 		alert NoticeOfError
 		cats  []Categories
 		cat   []Categories
+		head  []string
 		raw   [][]string
+		first int
+		notes diagnostics
 	)
 
-	// using Join() from path/filepath
-	sections := map[string]string{
-		"Assets":      filepath.Join(q.Path, q.Chart.Assets),
-		"Liabilities": filepath.Join(q.Path, q.Chart.Liabilities),
-		"Equity":      filepath.Join(q.Path, q.Chart.Equity),
-		"Revenues":    filepath.Join(q.Path, q.Chart.Revenues),
-		"Expenses":    filepath.Join(q.Path, q.Chart.Expenses),
-		/*
-			"Assets":      q.Path + q.Chart.Assets,
-			"Liabilities": q.Path + q.Chart.Liabilities,
-			"Equity":      q.Path + q.Chart.Equity,
-			"Revenues":    q.Path + q.Chart.Revenues,
-			"Expenses":    q.Path + q.Chart.Expenses,
-		*/
-	}
-
-	for i := range sections {
-		file, alert = fileType(sections[i])
+	for _, section := range q.ChartSections() {
+		// using Join() from path/filepath
+		file, alert = fileType(filepath.Join(q.Path, section.File))
+		notes.add(alert)
 		/*
-			if alert.Error == nil || alert.Code != "" {
+			if alert.Err == nil || alert.Code != "" {
 				alert.Trace.Crumbs("gatherCategories")
 				return cats, alert
 			}
 		*/
 
-		if alert.Error != nil || alert.Code == CaseUnnamedFile {
+		if alert.Err != nil || alert.Code == CaseUnnamedFile {
 			alert.Trace.Crumbs("gatherCategories")
-			return cats, alert
+			return cats, notes.onto(alert)
 		}
 
-		raw, alert = file2mx(file, headers)
-		if alert.Error != nil {
+		layout := q.layout(section.Layout, file)
+		skip := layout.Dialect.headerRows(headers)
+
+		head, raw, first, alert = file2mx(file, layout.Dialect, skip)
+		notes.add(alert)
+		if alert.Err != nil {
 			alert.Trace.Crumbs("gatherCategories")
-			return cats, alert
+			return cats, notes.onto(alert)
 		}
-		cat, alert = mx2cats(raw, i)
-		if alert.Error != nil {
+		cat, alert = mx2cats(head, raw, section.Name, layout, filepath.Base(file), first)
+		notes.add(alert)
+		if alert.Err != nil {
 			alert.Trace.Crumbs("gatherCategories")
-			return cats, alert
+			return cats, notes.onto(alert)
 		}
 
 		cats = append(cats, cat...)
@@ -162,13 +221,14 @@ func gatherCategories(q Schema, headers bool) ([]Categories, NoticeOfError) {
 		return cats[i].Cat < cats[j].Cat
 	})
 
-	return cats, alert
+	return cats, notes.onto(alert)
 }
 
-// fileType detects whether the provided file name has a '.csv' extension and
-// either:
+// fileType detects whether the provided file name has a '.csv', '.xlsx' or
+// '.ods' extension and either:
 // (1) adds '.csv' to the file name if no extension is provided or
-// (2) returns a warning of a wrong file type
+// (2) returns a warning of a wrong file type.
+// A sheet of a workbook may follow the file name, e.g. 'book.xlsx#Assets'.
 func fileType(filename string) (string, NoticeOfError) {
 	var (
 		nameFull string
@@ -179,24 +239,38 @@ func fileType(filename string) (string, NoticeOfError) {
 	// that path=dir+file
 	// Otherwise, it's possible to correctly join elements of the path
 	// using Join() from path/filepath
-	d, f := filepath.Split(filename)
-	ext := strings.ToLower(filepath.Ext(filename))
+	name, sheet := splitSheet(filename)
+	d, f := filepath.Split(name)
+	ext := strings.ToLower(filepath.Ext(name))
 
 	switch {
-	case ext == ".csv":
+	case ext == ".csv" && sheet == "":
+		return filename, alert
+
+	case (ext == ".xlsx" || ext == ".ods") && f != "":
 		return filename, alert
 
-	case f != "" && ext == "":
+	case f != "" && ext == "" && sheet == "":
 		// No extension, add '.csv'
 		nameFull = filename + ".csv"
-		fmt.Println("Extended: ", nameFull)
+		alert.Diagnostics = []Diagnostic{{
+			Severity: SeverityWarning,
+			Code:     CaseWrongFileType,
+			File:     filename,
+			Message:  "no file extension, read as '" + nameFull + "'",
+		}}
 		return nameFull, alert
 
-	case f != "" && ext != ".csv":
-		fmt.Println("Unrecognized extension! ", filename)
+	case f != "":
+		fmt.Fprintln(os.Stderr, "Unrecognized extension! ", filename)
 		alert = NoticeOfError{
-			Code: CaseWrongFileType,
-			Hint: "File '" + filename + "' has an unacceptable extension '" + ext + "'",
+			Code:     CaseWrongFileType,
+			Resource: filename,
+			Hint:     "File '" + filename + "' has an unacceptable extension '" + ext + "'; use .csv, .xlsx or .ods",
+			Err:      errors.New("unacceptable file type '" + ext + "'"),
+		}
+		if sheet != "" && !isWorkbook(filename) {
+			alert.Hint = "Only sheets of .xlsx and .ods workbooks may be referred to, e.g. 'book.xlsx#Assets'"
 		}
 		alert.Trace.Crumbs("fileType")
 		return filename, alert
@@ -206,7 +280,7 @@ func fileType(filename string) (string, NoticeOfError) {
 		alert = NoticeOfError{
 			Code: CaseUnnamedFile,
 		}
-		fmt.Println("Unnamed records file, skipped it!")
+		fmt.Fprintln(os.Stderr, "Unnamed records file, skipped it!")
 		return filename, alert
 
 	case d == "" && f == "":
@@ -215,99 +289,178 @@ func fileType(filename string) (string, NoticeOfError) {
 			Code: CaseNoData,
 			Hint: "No path and no file name provided",
 		}
-		fmt.Println("No path and no file name provided!")
+		fmt.Fprintln(os.Stderr, "No path and no file name provided!")
 		return filename, alert
 
 	default:
 		// Anything else
-		fmt.Println("Oops... ", filename)
+		fmt.Fprintln(os.Stderr, "Oops... ", filename)
 		return filename, alert
 	}
 }
 
-// file2mx reads file and puts CSV data into a [][]string matrix (raws-columns)
-func file2mx(filename string, headers bool) ([][]string, NoticeOfError) {
+// file2mx reads file and puts CSV or sheet data into a [][]string matrix (raws-columns).
+// Blank rows above the data are left out, and so are the first skip rows
+// that follow; the last of them is returned as the title row. The number of
+// the first data row in the file (1-based) is returned to trace records
+// back to the file.
+func file2mx(filename string, d Dialect, skip int) ([]string, [][]string, int, NoticeOfError) {
 	var (
 		alert NoticeOfError
+		head  []string
 		mx    [][]string
 	)
-	mx, alert = readFileCsv(filename)
-	if alert.Error != nil {
+	mx, alert = readFile(filename, d)
+	if alert.Err != nil {
 		alert.Trace.Crumbs("file2mx")
-		return mx, alert
+		return head, mx, 1, alert
 	}
 
-	switch headers {
-	case true:
+	top := 0
+	for top < len(mx) && blankRow(mx[top]) {
+		top++
+	}
+	mx = mx[top:]
+
+	switch {
+	case skip > 0 && len(mx) >= skip:
+		// Take the title rows (first elements) out of the slice
+		head = mx[skip-1]
+		mx = mx[skip:]
+		return head, mx, top + skip + 1, alert
 
-		// Delete the title row (first element from the slice)
-		mx = append(mx[:0], mx[1:]...)
-		return mx, alert
+	case skip > 0:
+		// No data rows
+		return head, nil, top + skip + 1, alert
 
 	default:
-		return mx, alert
+		return head, mx, top + 1, alert
 	}
 }
 
 // mx2cats puts data from a matrix of read input into a slice of
-// Categories objects. No data validation.
-func mx2cats(raw [][]string, section string) ([]Categories, NoticeOfError) {
+// Categories objects. Columns and the number format are taken as set in
+// the template; the default columns are Category, Name and Balance.
+// No data validation.
+func mx2cats(head []string, raw [][]string, section string, layout Layout, file string, first int) ([]Categories, NoticeOfError) {
 	var (
 		alert NoticeOfError
 		one   Categories
 		all   []Categories
+		diags []Diagnostic
 	)
-	all = make([]Categories, len(raw))
+
+	cols, err := layout.Columns.chartColumns(head)
+	if err != nil {
+		alert = NoticeOfError{
+			Code:     CaseWrongFormat,
+			Resource: file,
+			Err:      err,
+			Hint:     "Check the columns set for '" + file + "' in the template",
+		}
+		alert.Trace.Crumbs("mx2cats")
+		return all, alert
+	}
+
+	all = make([]Categories, 0, len(raw))
 	for i, each := range raw {
-		bal, err := strconv.ParseFloat(each[2], 64) //col3
+		if blankRow(each) {
+			continue
+		}
+
+		bal, err := parseAmount(cell(each, cols.balance), layout.Numbers)
 		if err != nil {
-			alert = NoticeOfError{
-				Code:  CaseWrongFormat,
-				Error: err,
-				Hint:  "WARNING! Balance '" + each[2] + "' read as '" + fmt.Sprintf("%f", bal) + "'",
-			}
-			alert.Trace.Crumbs("mx2cats")
+			diags = append(diags, badCell(file, first+i, cols.balance, amountProblem("balance", cell(each, cols.balance), err)))
 		}
 		one = Categories{
-			Cat:  each[0], //col1
-			Sect: section,
-			Name: each[1], //col2
+			Cat:    cell(each, cols.cat),
+			Sect:   section,
+			Name:   cell(each, cols.name),
+			Parent: strings.TrimSpace(cell(each, cols.parent)),
 			Bal: Tally{
-				Sta: bal, //col3
+				Sta: bal,
 			},
+			File: file,
+			Row:  first + i,
+			col:  cols.cat + 1,
 		}
-		all[i] = one
+		all = append(all, one)
+	}
+
+	// Note: all wrong values are listed
+	alert = wrongCells(file, diags)
+	if alert.Err != nil {
+		alert.Trace.Crumbs("mx2cats")
 	}
 	return all, alert
 }
 
 // readTransactions puts data from a matrix of read input into a slice of
-// Transactions objects. No data validation.
-func readTransactions(mx [][]string) ([]Transactions, NoticeOfError) {
+// Transactions objects. Columns and the number format are taken as set in
+// the template; the default columns are Amount, Source, Purpose and Date.
+// The file name, the number of the row (first is the number of the first
+// row of the matrix) and the row itself are kept in each record to trace it
+// back; the other columns are kept by their titles.
+// No data validation.
+func readTransactions(head []string, mx [][]string, layout Layout, file string, first int) ([]Transactions, NoticeOfError) {
 	var (
 		alert NoticeOfError
 		one   Transactions
 		all   []Transactions
+		diags []Diagnostic
 	)
-	all = make([]Transactions, len(mx))
+
+	cols, err := layout.Columns.recordColumns(head)
+	if err != nil {
+		alert = NoticeOfError{
+			Code:     CaseWrongFormat,
+			Resource: file,
+			Err:      err,
+			Hint:     "Check the columns set for '" + file + "' in the template",
+		}
+		alert.Trace.Crumbs("readTransactions")
+		return all, alert
+	}
+
+	names := extraTitles(head, cols.read())
+
+	all = make([]Transactions, 0, len(mx))
 	for i, each := range mx {
-		// The monetary value of transaction must be in the 1st column
-		amount, err := strconv.ParseFloat(each[0], 64) //col1
+		if blankRow(each) {
+			continue
+		}
+
+		amount, err := parseAmount(cell(each, cols.amount), layout.Numbers)
 		if err != nil {
-			alert = NoticeOfError{
-				Code:  CaseWrongFormat,
-				Error: err,
-				Hint:  "WARNING! Amount '" + each[0] + "' read as '" + fmt.Sprintf("%f", amount) + "'",
-			}
-			alert.Trace.Crumbs("readTransactions")
+			diags = append(diags, badCell(file, first+i, cols.amount, amountProblem("amount", cell(each, cols.amount), err)))
+		}
+
+		// The date is optional
+		date, err := parseDate(cell(each, cols.date))
+		if err != nil {
+			diags = append(diags, badCell(file, first+i, cols.date, err.Error()))
 		}
 
 		one = Transactions{
-			Amount:  amount,  //col1
-			Source:  each[1], //col2
-			Purpose: each[2], //col3
+			Amount:  amount,
+			Source:  cell(each, cols.source),
+			Purpose: cell(each, cols.purpose),
+			Date:    date,
+			Memo:    cell(each, cols.memo),
+			Ref:     cell(each, cols.ref),
+			File:    file,
+			Row:     first + i,
+			Raw:     each,
+			Meta:    metadata(names, cols.read(), each),
+			cols:    [2]int{cols.source + 1, cols.purpose + 1},
 		}
-		all[i] = one
+		all = append(all, one)
+	}
+
+	// Note: all wrong values are listed
+	alert = wrongCells(file, diags)
+	if alert.Err != nil {
+		alert.Trace.Crumbs("readTransactions")
 	}
 	return all, alert
 }
@@ -0,0 +1,209 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package conti provides business logic of trial account calculation
+package conti
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rules to find parents of categories
+const (
+	HierarchyParent = "parent" // By the Parent column of the Chart of Accounts
+	HierarchyPrefix = "prefix" // By the longest category id which is a prefix
+)
+
+// linkCategories finds parents of categories by the rule set in the template
+// and checks that each parent is a known category of the same section and
+// that no category is its own ancestor
+func linkCategories(cats []Categories, rule string) ([]Categories, NoticeOfError) {
+	var (
+		alert NoticeOfError
+		lines []string
+	)
+
+	switch strings.ToLower(strings.TrimSpace(rule)) {
+	case "", HierarchyParent:
+		// Parents are read from the Chart of Accounts
+
+	case HierarchyPrefix:
+		cats = prefixParents(cats)
+
+	default:
+		alert = NoticeOfError{
+			Code: CaseWrongFormat,
+			Hint: "Set the hierarchy of categories in the template to '" + HierarchyParent + "' or '" + HierarchyPrefix + "'",
+			Err:  fmt.Errorf("unknown hierarchy rule '%s'", rule),
+		}
+		alert.Trace.Crumbs("linkCategories")
+		return cats, alert
+	}
+
+	parent := map[string]string{}
+	cSec := catSec(cats)
+	for _, c := range cats {
+		parent[c.Cat] = c.Parent
+	}
+
+	for _, c := range cats {
+		if c.Parent == "" {
+			continue
+		}
+		s, ok := cSec[c.Parent]
+		switch {
+		case !ok:
+			lines = append(lines, fmt.Sprintf("Category '%s' (%s): unknown parent '%s'", c.Cat, c.Sect, c.Parent))
+			continue
+
+		case s != c.Sect:
+			lines = append(lines, fmt.Sprintf("Category '%s' (%s): parent '%s' is in another section (%s)", c.Cat, c.Sect, c.Parent, s))
+			continue
+		}
+
+		// Note: a chain of parents longer than the number of categories
+		// has a cycle
+		up := c.Parent
+		for i := 0; up != "" && i <= len(cats); i++ {
+			if up == c.Cat {
+				lines = append(lines, fmt.Sprintf("Category '%s' (%s): the category is its own ancestor", c.Cat, c.Sect))
+				break
+			}
+			up = parent[up]
+		}
+	}
+
+	if len(lines) == 0 {
+		return cats, alert
+	}
+
+	alert = NoticeOfError{
+		Code: CaseCategoryNotKnown,
+		Hint: "Correct parents in the Chart of Accounts:\n" + strings.Join(lines, "\n"),
+		Err:  fmt.Errorf("%d wrong parent(s) of categories", len(lines)),
+	}
+	alert.Trace.Crumbs("linkCategories")
+	return cats, alert
+}
+
+// prefixParents sets the parent of each category with no parent to the
+// category of the same section with the longest id which is a prefix of
+// the id. Trailing 'x' in ids of groups stand for any digit, e.g. '5xx' is
+// the parent of '500' and '510'.
+func prefixParents(cats []Categories) []Categories {
+	out := make([]Categories, len(cats))
+	copy(out, cats)
+
+	for i := range out {
+		if out[i].Parent != "" {
+			continue
+		}
+
+		best := ""
+		for _, c := range cats {
+			stem := strings.TrimRight(c.Cat, "xX")
+			if c.Sect != out[i].Sect || c.Cat == out[i].Cat || stem == "" {
+				continue
+			}
+			if len(stem) < len(out[i].Cat) && strings.HasPrefix(out[i].Cat, stem) &&
+				len(stem) > len(strings.TrimRight(best, "xX")) {
+				best = c.Cat
+			}
+		}
+		out[i].Parent = best
+	}
+	return out
+}
+
+// RollUp arranges categories as a tree, so that each category is followed by
+// its children, and adds up the values of each category and of all its
+// descendants. Level is set to the depth of a category, 0 for top ones.
+// Note: the values of a parent include its own values, e.g. of records
+// posted to a group of accounts.
+func RollUp(cats []Categories) []Categories {
+	var visit func(i, level int) Tally
+
+	known := map[string]bool{}
+	for _, c := range cats {
+		known[c.Cat] = true
+	}
+
+	children := map[string][]int{}
+	var roots []int
+	for i, c := range cats {
+		if c.Parent == "" || !known[c.Parent] {
+			roots = append(roots, i)
+			continue
+		}
+		children[c.Parent] = append(children[c.Parent], i)
+	}
+
+	out := make([]Categories, 0, len(cats))
+	seen := make([]bool, len(cats))
+
+	visit = func(i, level int) Tally {
+		// Note: guard against cycles, which are rejected beforehand
+		if seen[i] {
+			return Tally{}
+		}
+		seen[i] = true
+
+		pos := len(out)
+		one := cats[i]
+		one.Level = level
+		out = append(out, one)
+
+		sum := one.Bal
+		for _, j := range children[one.Cat] {
+			sum = sum.plus(visit(j, level+1))
+		}
+		out[pos].Bal = sum
+		return sum
+	}
+
+	for _, i := range roots {
+		visit(i, 0)
+	}
+	return out
+}
+
+// Collapse leaves out categories deeper than depth levels of the hierarchy,
+// e.g. depth 1 leaves top categories only; any depth below 1 keeps all
+func Collapse(cats []Categories, depth int) []Categories {
+	if depth < 1 {
+		return cats
+	}
+
+	out := make([]Categories, 0, len(cats))
+	for _, c := range cats {
+		if c.Level < depth {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Depth returns the number of levels of the hierarchy of categories
+func Depth(cats []Categories) int {
+	depth := 0
+	for _, c := range cats {
+		if c.Level+1 > depth {
+			depth = c.Level + 1
+		}
+	}
+	return depth
+}
+
+// childRows returns the indices of the children of the category at i in a
+// list of categories arranged as a tree
+func childRows(cats []Categories, i int) []int {
+	var rows []int
+	for j := i + 1; j < len(cats) && cats[j].Level > cats[i].Level; j++ {
+		if cats[j].Level == cats[i].Level+1 {
+			rows = append(rows, j)
+		}
+	}
+	return rows
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package conti provides business logic of trial account calculation
+package conti
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+)
+
+// Note: classes of table rows are kinds of statement lines
+var statementsHtml = template.Must(template.New("statements").Funcs(template.FuncMap{
+	"indent": func(level int) template.CSS {
+		return template.CSS(fmt.Sprintf("padding-left: %.1fem", 0.8+1.5*float64(level)))
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { padding: 0.2em 0.8em; }
+th { text-align: left; border-bottom: 1px solid #000; }
+td.value, th.value { text-align: right; white-space: nowrap; }
+tr.heading td { font-weight: bold; padding-top: 0.8em; }
+tr.subtotal td { font-weight: bold; border-top: 1px solid #000; }
+tr.total td { font-weight: bold; border-top: 3px double #000; }
+p.fail { color: #b00020; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="{{if .Verdict.Balanced}}pass{{else}}fail{{end}}">{{.Verdict.Banner}}</p>
+{{- range .Verdict.Imbalances}}
+<p class="fail">{{.}}</p>
+{{- end}}
+{{range .Statements}}
+<h2>{{.Title}}</h2>
+<table>
+<tr><th>Cat</th><th>Name</th><th class="value">Starting</th><th class="value">Change</th><th class="value">Ending</th></tr>
+{{- range .Lines}}
+{{- if eq .Kind "heading"}}
+<tr class="{{.Kind}}"><td colspan="5">{{.Name}}</td></tr>
+{{- else}}
+<tr class="{{.Kind}}"><td>{{.Cat}}</td><td style="{{indent .Level}}">{{.Name}}</td><td class="value">{{.Value.Sta}}</td><td class="value">{{.Value.Dif}}</td><td class="value">{{.Value.End}}</td></tr>
+{{- end}}
+{{- end}}
+</table>
+{{end}}
+</body>
+</html>
+`))
+
+// TO DO: TRACE errors!!!
+// ExportAccountsToHtml writes the financial statements in an HTML file
+func ExportAccountsToHtml(conti []Categories, rep Report, depth int, filename string) {
+	htmlNewFile, err := os.Create(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error (creating file %s): %s\n", filename, err)
+		return
+	}
+	defer htmlNewFile.Close()
+
+	err = WriteAccountsHtml(htmlNewFile, conti, rep, depth)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error (writing file %s): %s\n", filename, err)
+	}
+}
+
+// WriteAccountsHtml writes the Balance Sheet and the Profit & Loss Statement
+// as an HTML page to w, headed with the trial balance verdict. Categories are
+// rolled up and shown to depth levels of the hierarchy, all levels if depth
+// is 0.
+func WriteAccountsHtml(w io.Writer, conti []Categories, rep Report, depth int) error {
+	return statementsHtml.Execute(w, struct {
+		Title      string
+		Verdict    Verdict
+		Statements []Statement
+	}{
+		Title:      "Financial Statements",
+		Verdict:    TrialBalance(rep),
+		Statements: Statements(conti, rep, depth),
+	})
+}
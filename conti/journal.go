@@ -0,0 +1,271 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package conti provides business logic of trial account calculation
+package conti
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FormatJournal is the format of a file of journal entries, e.g.
+//
+//	Entry,Date,Account,Debit,Credit,Description
+//	J1,2020-03-31,520,100.00,,Accounting services
+//	J1,,130,20.00,,VAT
+//	J1,,220,,120.00,ABC Accounting Ltd
+const FormatJournal = "journal"
+
+// leg represents a row of a journal entry: a category debited (a positive
+// value) or credited (a negative value)
+type leg struct {
+	cat   string
+	value Money
+	date  time.Time
+	memo  string
+	ref   string
+	row   int
+	raw   []string
+	meta  map[string]string
+	col   int
+}
+
+// legPair keeps the legs of a journal entry decomposed into a record: the
+// leg of the category credited (the source) and of the category debited
+// (the purpose)
+type legPair struct {
+	source, purpose leg
+}
+
+// entry represents a journal entry: consecutive rows sharing an entry ID
+type entry struct {
+	id   string
+	legs []leg
+}
+
+// readJournal puts journal entries from a matrix of read input into a slice
+// of Transactions objects. Consecutive rows sharing an entry ID form an
+// entry, and a row with no entry ID continues the entry above. Each entry
+// must balance: its debits must equal its credits. Entries are decomposed
+// into records of a source (the category credited) and a purpose (the
+// category debited), each traced back to a row of the entry.
+// No validation of categories.
+func readJournal(head []string, mx [][]string, layout Layout, file string, first int) ([]Transactions, NoticeOfError) {
+	var (
+		alert      NoticeOfError
+		all        []Transactions
+		entries    []entry
+		diags      []Diagnostic
+		entryDiags []Diagnostic
+	)
+
+	cols, err := layout.Columns.journalColumns(head)
+	if err != nil {
+		alert = NoticeOfError{
+			Code:     CaseWrongFormat,
+			Resource: file,
+			Err:      err,
+			Hint:     "Check the columns set for '" + file + "' in the template",
+		}
+		alert.Trace.Crumbs("readJournal")
+		return all, alert
+	}
+
+	names := extraTitles(head, cols.read())
+	seen := map[string]bool{}
+
+	for i, each := range mx {
+		if blankRow(each) {
+			continue
+		}
+
+		debit, err := parseLeg(cell(each, cols.debit), layout.Numbers)
+		if err != nil {
+			diags = append(diags, badCell(file, first+i, cols.debit, amountProblem("debit", cell(each, cols.debit), err)))
+		}
+		credit, err := parseLeg(cell(each, cols.credit), layout.Numbers)
+		if err != nil {
+			diags = append(diags, badCell(file, first+i, cols.credit, amountProblem("credit", cell(each, cols.credit), err)))
+		}
+
+		// The date is optional
+		date, err := parseDate(cell(each, cols.date))
+		if err != nil {
+			diags = append(diags, badCell(file, first+i, cols.date, err.Error()))
+		}
+
+		one := leg{
+			cat:   strings.TrimSpace(cell(each, cols.account)),
+			value: debit - credit,
+			date:  date,
+			memo:  cell(each, cols.memo),
+			ref:   cell(each, cols.ref),
+			row:   first + i,
+			raw:   each,
+			meta:  metadata(names, cols.read(), each),
+			col:   cols.account + 1,
+		}
+
+		id := strings.TrimSpace(cell(each, cols.entry))
+		if len(entries) != 0 && (id == "" || id == entries[len(entries)-1].id) {
+			// The entry goes on
+			last := &entries[len(entries)-1]
+			last.legs = append(last.legs, one)
+			continue
+		}
+
+		switch {
+		case id == "":
+			entryDiags = append(entryDiags, badEntry(file, one.row, cols.entry, "no entry ID"))
+			continue
+
+		case seen[id]:
+			entryDiags = append(entryDiags, badEntry(file, one.row, cols.entry, "entry '"+id+"' is apart from its rows above"))
+		}
+
+		seen[id] = true
+		entries = append(entries, entry{id: id, legs: []leg{one}})
+	}
+
+	// Note: all wrong values are listed
+	alert = wrongCells(file, diags)
+	if alert.Err != nil {
+		alert.Trace.Crumbs("readJournal")
+		return all, alert
+	}
+
+	for _, e := range entries {
+		recs, d := e.records(file, cols.debit)
+		if d != nil {
+			entryDiags = append(entryDiags, *d)
+			continue
+		}
+		all = append(all, recs...)
+	}
+
+	if len(entryDiags) != 0 {
+		lines := make([]string, len(entryDiags))
+		for i, d := range entryDiags {
+			lines[i] = fmt.Sprintf("File '%s', row %d: %s", file, d.Row, d.Message)
+		}
+		alert = NoticeOfError{
+			Code:        CaseUnbalancedEntry,
+			Resource:    file,
+			Hint:        "Correct the journal entries so that the debits of every entry equal its credits:\n" + strings.Join(lines, "\n"),
+			Err:         fmt.Errorf("%d wrong journal entries in '%s'", len(lines), file),
+			Diagnostics: entryDiags,
+		}
+		alert.Trace.Crumbs("readJournal")
+	}
+	return all, alert
+}
+
+// badEntry returns a diagnostic of a wrong journal entry; col is the 0-based
+// index of the column
+func badEntry(file string, row, col int, msg string) Diagnostic {
+	d := badCell(file, row, col, msg)
+	d.Code = CaseUnbalancedEntry
+	return d
+}
+
+// parseLeg parses a debit or a credit of a journal entry; an empty cell is
+// zero
+func parseLeg(s string, n Numbers) (Money, error) {
+	if strings.TrimSpace(s) == "" {
+		return 0, nil
+	}
+	return parseAmount(s, n)
+}
+
+// ***************************************************************************
+// * METHODS
+// ***************************************************************************
+
+// records checks that the entry balances and decomposes it into records:
+// debits and credits are matched in the order of rows, each record carrying
+// the smaller of the remaining values from the category credited to the
+// category debited. A record is traced back to the later row of the two,
+// and takes its date from the entry unless the row has one. An entry which
+// doesn't balance is diagnosed at its first row and the debit column col.
+func (e entry) records(file string, col int) ([]Transactions, *Diagnostic) {
+	var (
+		recs            []Transactions
+		debits, credits []leg
+		dTotal, cTotal  Money
+		date            time.Time
+		memo            string
+	)
+
+	for _, one := range e.legs {
+		if date.IsZero() {
+			date = one.date
+		}
+		if memo == "" {
+			memo = one.memo
+		}
+
+		switch {
+		case one.value > 0:
+			debits = append(debits, one)
+			dTotal += one.value
+		case one.value < 0:
+			one.value = -one.value
+			credits = append(credits, one)
+			cTotal += one.value
+		}
+	}
+
+	if dTotal != cTotal {
+		d := badEntry(file, e.legs[0].row, col, fmt.Sprintf("entry '%s' of rows %d-%d does not balance: debits %s, credits %s",
+			e.id, e.legs[0].row, e.legs[len(e.legs)-1].row, dTotal, cTotal))
+		return nil, &d
+	}
+
+	d, c := 0, 0
+	for d < len(debits) && c < len(credits) {
+		amount := debits[d].value
+		if credits[c].value < amount {
+			amount = credits[c].value
+		}
+
+		at := debits[d]
+		if credits[c].row > at.row {
+			at = credits[c]
+		}
+
+		rec := Transactions{
+			Amount:  amount,
+			Source:  credits[c].cat,
+			Purpose: debits[d].cat,
+			Date:    at.date,
+			Memo:    at.memo,
+			Ref:     at.ref,
+			File:    file,
+			Row:     at.row,
+			Raw:     at.raw,
+			Meta:    at.meta,
+			legs:    &legPair{source: credits[c], purpose: debits[d]},
+			cols:    [2]int{credits[c].col, debits[d].col},
+		}
+		if rec.Date.IsZero() {
+			rec.Date = date
+		}
+		if rec.Memo == "" {
+			rec.Memo = memo
+		}
+		recs = append(recs, rec)
+
+		debits[d].value -= amount
+		credits[c].value -= amount
+		if debits[d].value == 0 {
+			d++
+		}
+		if credits[c].value == 0 {
+			c++
+		}
+	}
+	return recs, nil
+}
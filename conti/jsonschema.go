@@ -0,0 +1,170 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package conti provides business logic of trial account calculation
+package conti
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// jsonObject is an object of a JSON Schema
+type jsonObject map[string]interface{}
+
+// schemaKeywords adds keywords to the schemas of fields, by the type and
+// the field, e.g. allowed values
+var schemaKeywords = map[string]jsonObject{
+	"Schema.Version":    {"minimum": 1, "maximum": SchemaVersion},
+	"Schema.Hierarchy":  {"enum": []string{HierarchyParent, HierarchyPrefix}},
+	"Section.Side":      {"enum": []string{SideDebit, SideCredit}},
+	"Section.Statement": {"enum": []string{StatementBalance, StatementProfit, StatementOffBalance}},
+	"Record.Format":     {"enum": []string{FormatJournal}},
+}
+
+// schemaRequired lists the keys required in objects, by the type
+var schemaRequired = map[string][]string{
+	"Schema":    {"records"},
+	"Record":    {"id"},
+	"ChartFile": {"file"},
+	"Section":   {"name", "side", "statement", "file"},
+}
+
+// schemaDefs keeps the schemas of named struct types, referred to as
+// '#/definitions/<name>'
+type schemaDefs map[string]jsonObject
+
+// TemplateJSONSchema returns a JSON Schema of templates of SchemaVersion,
+// generated from the types of Schema, so that editors may validate and
+// complete templates. Keys are named as in YAML templates; unknown keys
+// are not allowed, as on loading a template.
+func TemplateJSONSchema() ([]byte, error) {
+	defs := schemaDefs{}
+	root := defs.structSchema(reflect.TypeOf(Schema{}))
+	root["$schema"] = "http://json-schema.org/draft-07/schema#"
+	root["title"] = "Kitri template"
+	root["definitions"] = defs
+	return json.MarshalIndent(root, "", "  ")
+}
+
+// yamlKey returns the key of a field in YAML templates and whether the
+// fields of the field are inline
+func yamlKey(f reflect.StructField) (string, bool) {
+	tag := strings.Split(f.Tag.Get("yaml"), ",")
+	for _, flag := range tag[1:] {
+		if flag == "inline" {
+			return "", true
+		}
+	}
+	if tag[0] != "" {
+		return tag[0], false
+	}
+
+	// Note: the default key of YAML is the name of the field in lower case
+	return strings.ToLower(f.Name), false
+}
+
+// ***************************************************************************
+// * METHODS
+// ***************************************************************************
+
+// typeSchema returns the schema of a type; field is the type and the field
+// of a struct having the type, e.g. 'Section.Side', if any. Named struct
+// types are referred to in the definitions.
+func (defs schemaDefs) typeSchema(t reflect.Type, field string) jsonObject {
+	var out jsonObject
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == reflect.TypeOf(Column("")):
+		// Note: a 1-based index or a title
+		out = jsonObject{"type": []string{"integer", "string"}}
+
+	case t == reflect.TypeOf(ChartFile{}):
+		// Note: a file name or a mapping
+		out = defs.ref(t, func() jsonObject {
+			return jsonObject{"oneOf": []jsonObject{{"type": "string"}, defs.structSchema(t)}}
+		})
+
+	case t.Kind() == reflect.String:
+		out = jsonObject{"type": "string"}
+
+	case t.Kind() == reflect.Bool:
+		out = jsonObject{"type": "boolean"}
+
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		out = jsonObject{"type": "integer"}
+
+	case t.Kind() == reflect.Slice:
+		out = jsonObject{"type": "array", "items": defs.typeSchema(t.Elem(), "")}
+
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		out = defs.ref(t, func() jsonObject { return defs.structSchema(t) })
+
+	case t.Kind() == reflect.Struct:
+		out = defs.structSchema(t)
+
+	default:
+		out = jsonObject{}
+	}
+
+	for k, v := range schemaKeywords[field] {
+		out[k] = v
+	}
+	return out
+}
+
+// ref returns a reference to the definition of a named type, made by def
+// unless defined yet
+func (defs schemaDefs) ref(t reflect.Type, def func() jsonObject) jsonObject {
+	if _, ok := defs[t.Name()]; !ok {
+		// Note: set before def is called, in case the type refers to itself
+		defs[t.Name()] = jsonObject{}
+		defs[t.Name()] = def()
+	}
+	return jsonObject{"$ref": "#/definitions/" + t.Name()}
+}
+
+// structSchema returns the schema of an object of a struct type
+func (defs schemaDefs) structSchema(t reflect.Type) jsonObject {
+	props := jsonObject{}
+	defs.addFields(t, t.Name(), props)
+
+	out := jsonObject{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if keys, ok := schemaRequired[t.Name()]; ok {
+		out["required"] = keys
+	}
+	return out
+}
+
+// addFields adds the schemas of the exported fields of a struct type to
+// props, including the fields of inline structs; owner is the name of the
+// type having the fields
+func (defs schemaDefs) addFields(t reflect.Type, owner string, props jsonObject) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			// Unexported
+			continue
+		}
+
+		name, inline := yamlKey(f)
+		switch {
+		case name == "-":
+			continue
+		case inline:
+			defs.addFields(f.Type, owner, props)
+		default:
+			props[name] = defs.typeSchema(f.Type, owner+"."+f.Name)
+		}
+	}
+}
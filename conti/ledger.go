@@ -0,0 +1,249 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package conti provides business logic of trial account calculation
+package conti
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Ledger represents the general-ledger account of a category: the records
+// posted to the category in the reporting period with a running balance
+type Ledger struct {
+	// Category id, section and name
+	Cat  string
+	Sect string
+	Name string
+
+	// Starting value, including records dated before the reporting period
+	Opening Money
+
+	// Records posted to the category in the order of input
+	Postings []Posting
+
+	// Ending value
+	Closing Money
+}
+
+// Posting represents a record posted to a category
+type Posting struct {
+	// The date of transaction; zero if not provided
+	Date time.Time
+
+	// The name of the file the record is read from and the number of the
+	// row in the file (1-based, title rows included)
+	File string
+	Row  int
+
+	// The counter-account: the other category of the record
+	Counter     string
+	CounterName string
+
+	// Optional description and reference of the record
+	Memo string
+	Ref  string
+
+	// The change of the category value, in the sign of the normal balance
+	// of the section
+	Amount Money
+
+	// The category value after the posting
+	Balance Money
+
+	// The other columns of the record by their titles
+	Meta map[string]string
+}
+
+// AccountsLedger lists the records posted to the categories in the
+// reporting period with running balances; all categories with records are
+// listed if none are given.
+func AccountsLedger(q Schema, cats ...string) ([]Ledger, NoticeOfError) {
+	var (
+		alert   NoticeOfError
+		ledgers []Ledger
+		unknown []string
+	)
+
+	b, alert := openBooks(q)
+	if alert.Err != nil {
+		alert.Trace.Crumbs("AccountsLedger")
+		return nil, alert
+	}
+
+	if len(cats) == 0 {
+		for _, one := range postLedgers(b) {
+			if len(one.Postings) != 0 {
+				ledgers = append(ledgers, one)
+			}
+		}
+		return ledgers, alert
+	}
+
+	byCat := map[string]Ledger{}
+	for _, one := range postLedgers(b) {
+		byCat[one.Cat] = one
+	}
+	for _, cat := range cats {
+		one, ok := byCat[strings.TrimSpace(cat)]
+		if !ok {
+			unknown = append(unknown, "'"+cat+"'")
+			continue
+		}
+		ledgers = append(ledgers, one)
+	}
+
+	if len(unknown) != 0 {
+		alert = NoticeOfError{
+			Code: CaseCategoryNotKnown,
+			Hint: "Categories not found in the Chart of Accounts: " + strings.Join(unknown, ", "),
+			Err:  fmt.Errorf("%d unknown categories", len(unknown)),
+
+			Diagnostics: alert.Diagnostics,
+		}
+		alert.Trace.Crumbs("AccountsLedger")
+	}
+	return ledgers, alert
+}
+
+// postLedgers posts the records to the ledgers of categories, in the order
+// of the Chart of Accounts
+func postLedgers(b books) []Ledger {
+	cSec := catSec(b.cats)
+	sides := sectionSides(b.sections)
+
+	names := map[string]string{}
+	index := map[string]int{}
+	ledgers := make([]Ledger, len(b.cats))
+	for i, c := range b.cats {
+		names[c.Cat] = c.Name
+		index[c.Cat] = i
+		ledgers[i] = Ledger{
+			Cat:     c.Cat,
+			Sect:    c.Sect,
+			Name:    c.Name,
+			Opening: c.Bal.Sta,
+			Closing: c.Bal.Sta,
+		}
+	}
+
+	post := func(cat, counter string, amount Money, rec Transactions, own *leg) {
+		i, ok := index[cat]
+		if !ok {
+			return
+		}
+		l := &ledgers[i]
+		l.Closing += amount
+
+		one := Posting{
+			Date:        rec.Date,
+			File:        rec.File,
+			Row:         rec.Row,
+			Counter:     counter,
+			CounterName: names[counter],
+			Memo:        rec.Memo,
+			Ref:         rec.Ref,
+			Amount:      amount,
+			Balance:     l.Closing,
+			Meta:        rec.Meta,
+		}
+
+		// Note: a record decomposed from a journal entry is traced back to
+		// the leg of the category
+		if own != nil {
+			one.Row, one.Meta = own.row, own.meta
+			if own.memo != "" {
+				one.Memo = own.memo
+			}
+			if own.ref != "" {
+				one.Ref = own.ref
+			}
+		}
+		l.Postings = append(l.Postings, one)
+	}
+
+	// Note: the signs follow postTransactionsToAccounts
+	for _, rec := range b.records {
+		var source, purpose *leg
+		if rec.legs != nil {
+			source, purpose = &rec.legs.source, &rec.legs.purpose
+		}
+
+		if creditSide(cSec, sides, rec.Source) {
+			post(rec.Source, rec.Purpose, +rec.Amount, rec, source)
+		} else {
+			post(rec.Source, rec.Purpose, -rec.Amount, rec, source)
+		}
+
+		if creditSide(cSec, sides, rec.Purpose) {
+			post(rec.Purpose, rec.Source, -rec.Amount, rec, purpose)
+		} else {
+			post(rec.Purpose, rec.Source, +rec.Amount, rec, purpose)
+		}
+	}
+	return ledgers
+}
+
+// WriteLedgerCsv writes ledgers of categories in the CSV format to w: a
+// title row per category followed by the opening balance, the postings
+// with running balances and the closing balance. The other columns of the
+// records follow the balance, in the alphabetical order of their titles.
+func WriteLedgerCsv(w io.Writer, ledgers []Ledger) error {
+	writer := csv.NewWriter(w)
+	keys := ledgerMetaKeys(ledgers)
+	blank := make([]string, len(keys))
+
+	for i, l := range ledgers {
+		if i > 0 {
+			writer.Write([]string{})
+		}
+		writer.Write([]string{"Ledger", l.Cat, l.Name, l.Sect})
+		writer.Write(append([]string{"Date", "File", "Row", "Counter", "Counter Name", "Description", "Ref", "Amount", "Balance"}, keys...))
+		writer.Write(append([]string{"", "", "", "", "", "Opening balance", "", "", l.Opening.String()}, blank...))
+
+		for _, p := range l.Postings {
+			date := ""
+			if !p.Date.IsZero() {
+				date = p.Date.Format("2006-01-02")
+			}
+			row := ""
+			if p.Row > 0 {
+				row = fmt.Sprint(p.Row)
+			}
+			field := []string{date, p.File, row, p.Counter, p.CounterName, p.Memo, p.Ref, p.Amount.String(), p.Balance.String()}
+			for _, k := range keys {
+				field = append(field, p.Meta[k])
+			}
+			writer.Write(field)
+		}
+		writer.Write(append([]string{"", "", "", "", "", "Closing balance", "", "", l.Closing.String()}, blank...))
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// ledgerMetaKeys returns the titles of the other columns of the records
+// posted to the ledgers, sorted
+func ledgerMetaKeys(ledgers []Ledger) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, l := range ledgers {
+		for _, p := range l.Postings {
+			for k := range p.Meta {
+				if !seen[k] {
+					seen[k] = true
+					keys = append(keys, k)
+				}
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
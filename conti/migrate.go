@@ -0,0 +1,109 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package conti provides business logic of trial account calculation
+package conti
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// SchemaVersion is the version of the template format written by Kitri.
+// Templates of older versions are upgraded on load by MigrateSchema:
+//
+//	1  no 'version' key; a file of records is included with 'include: 1'
+//	   and left out with 'include: 0'
+//	2  'version: 2'; a file of records is included with 'include: true'
+const SchemaVersion = 2
+
+// migrations upgrade a template of a version to the next version; the
+// template is a document decoded from YAML or JSON with no types
+var migrations = map[int]func(doc map[string]interface{}){
+	1: includeFlags,
+}
+
+// MigrateSchema upgrades a template document decoded from YAML or JSON
+// with no types to SchemaVersion, and returns the version of the template
+// as read; a template with no version is of version 1. Templates of a
+// version newer than SchemaVersion are refused.
+func MigrateSchema(doc map[string]interface{}) (int, error) {
+	from, err := docVersion(doc)
+	if err != nil {
+		return 0, err
+	}
+
+	if from > SchemaVersion {
+		return from, fmt.Errorf("version %d of the template is newer than version %d read by this release", from, SchemaVersion)
+	}
+
+	for v := from; v < SchemaVersion; v++ {
+		migrations[v](doc)
+	}
+	doc["version"] = SchemaVersion
+	return from, nil
+}
+
+// docVersion returns the version of a template document
+func docVersion(doc map[string]interface{}) (int, error) {
+	value, ok := doc["version"]
+	if !ok || value == nil {
+		return 1, nil
+	}
+
+	// Note: YAML gives whole numbers as int, JSON as float64
+	var v int
+	switch n := value.(type) {
+	case int:
+		v = n
+	case float64:
+		if n != math.Trunc(n) {
+			return 0, fmt.Errorf("version '%v' of the template is not a whole number", value)
+		}
+		v = int(n)
+	default:
+		return 0, fmt.Errorf("version '%v' of the template is not a number", value)
+	}
+
+	if v < 1 {
+		return 0, fmt.Errorf("version %d of the template is unknown; versions start with 1", v)
+	}
+	return v, nil
+}
+
+// includeFlags upgrades a template of version 1: 'include' of records turns
+// from a number into a flag, any number but zero including the file.
+// Values of other types are left to be refused on decoding.
+func includeFlags(doc map[string]interface{}) {
+	records, _ := doc["records"].([]interface{})
+	for _, one := range records {
+		// Note: YAML gives nested mappings with keys of any type
+		switch rec := one.(type) {
+		case map[string]interface{}:
+			for k, v := range rec {
+				if strings.EqualFold(k, "include") {
+					rec[k] = numberFlag(v)
+				}
+			}
+		case map[interface{}]interface{}:
+			for k, v := range rec {
+				if key, ok := k.(string); ok && key == "include" {
+					rec[k] = numberFlag(v)
+				}
+			}
+		}
+	}
+}
+
+// numberFlag converts a number into a flag, true unless zero
+func numberFlag(v interface{}) interface{} {
+	switch n := v.(type) {
+	case int:
+		return n != 0
+	case float64:
+		return n != 0
+	}
+	return v
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package conti provides business logic of trial account calculation
+package conti
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money represents a monetary value as a fixed-point number with a fixed
+// number of decimal places. Sums of any number of amounts are exact, so
+// totals match spreadsheet totals to the cent.
+type Money int64
+
+const (
+	// The number of decimal places kept in Money values
+	moneyPlaces = 4
+
+	// The number of Money units in a unit of currency, i.e. 10^moneyPlaces
+	moneyScale Money = 10000
+
+	// The largest exponent of decimal strings, e.g. '1e100'
+	maxExponent = 100
+)
+
+// ErrDecimalPlaces is the cause of errors of numbers with significant digits
+// beyond the fourth decimal place, which Money can't keep
+var ErrDecimalPlaces = errors.New("more than 4 decimal places")
+
+// ParseMoney converts a decimal string, e.g. '-1234.5678', with an optional
+// exponent, e.g. '1.5e3', into Money. Numbers with significant digits beyond
+// the fourth decimal place, e.g. '0.12345', are refused with
+// ErrDecimalPlaces rather than rounded; trailing zeros are fine.
+// Note: the exponent shifts the decimal point of the digits, so that no
+// precision is lost through float64.
+func ParseMoney(s string) (Money, error) {
+	var (
+		neg      bool
+		exp      int
+		units    Money
+		fraction Money
+	)
+	num := strings.TrimSpace(s)
+
+	switch {
+	case strings.HasPrefix(num, "-"):
+		neg = true
+		num = num[1:]
+	case strings.HasPrefix(num, "+"):
+		num = num[1:]
+	}
+
+	if i := strings.IndexAny(num, "eE"); i >= 0 {
+		e := num[i+1:]
+		num = num[:i]
+
+		digits := strings.TrimLeft(e, "+-")
+		if len(e)-len(digits) > 1 || digits == "" || !isDigits(digits) {
+			return 0, &strconv.NumError{Func: "ParseMoney", Num: s, Err: strconv.ErrSyntax}
+		}
+		v, err := strconv.Atoi(digits)
+		if err != nil || v > maxExponent {
+			return 0, &strconv.NumError{Func: "ParseMoney", Num: s, Err: strconv.ErrRange}
+		}
+		exp = v
+		if strings.HasPrefix(e, "-") {
+			exp = -v
+		}
+	}
+
+	whole, frac := num, ""
+	if i := strings.IndexByte(num, '.'); i >= 0 {
+		whole, frac = num[:i], num[i+1:]
+	}
+
+	if (whole == "" && frac == "") || !isDigits(whole) || !isDigits(frac) {
+		return 0, &strconv.NumError{Func: "ParseMoney", Num: s, Err: strconv.ErrSyntax}
+	}
+
+	// Move the decimal point by the exponent
+	digits, point := whole+frac, len(whole)+exp
+	if point < 0 {
+		digits = strings.Repeat("0", -point) + digits
+		point = 0
+	}
+	if point > len(digits) {
+		digits += strings.Repeat("0", point-len(digits))
+	}
+	whole, frac = digits[:point], digits[point:]
+
+	for _, d := range whole {
+		units = units*10 + Money(d-'0')
+		if units > math.MaxInt64/moneyScale/10 {
+			return 0, &strconv.NumError{Func: "ParseMoney", Num: s, Err: strconv.ErrRange}
+		}
+	}
+
+	for i := 0; i < moneyPlaces; i++ {
+		fraction *= 10
+		if i < len(frac) {
+			fraction += Money(frac[i] - '0')
+		}
+	}
+
+	if len(frac) > moneyPlaces && strings.Trim(frac[moneyPlaces:], "0") != "" {
+		return 0, &strconv.NumError{Func: "ParseMoney", Num: s, Err: ErrDecimalPlaces}
+	}
+
+	m := units*moneyScale + fraction
+	if neg {
+		m = -m
+	}
+	return m, nil
+}
+
+// isDigits detects whether a string consists of decimal digits only
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ***************************************************************************
+// * METHODS
+// ***************************************************************************
+
+// String formats the value with at least two decimal places, e.g. '12.50',
+// and up to four if they are significant, e.g. '0.4975'
+func (m Money) String() string {
+	sign := ""
+	if m < 0 {
+		sign = "-"
+	}
+
+	u := uint64(m)
+	if m < 0 {
+		u = uint64(-(m + 1)) + 1
+	}
+
+	whole := strconv.FormatUint(u/uint64(moneyScale), 10)
+	frac := strconv.FormatUint(u%uint64(moneyScale)+uint64(moneyScale), 10)[1:]
+	for len(frac) > 2 && frac[len(frac)-1] == '0' {
+		frac = frac[:len(frac)-1]
+	}
+
+	return sign + whole + "." + frac
+}
+
+// Float64 converts the value into a float64 number, e.g. for display
+func (m Money) Float64() float64 {
+	return float64(m) / float64(moneyScale)
+}
+
+// Round rounds the value half away from zero to the given number of decimal
+// places (from 0 to 4)
+func (m Money) Round(places int) Money {
+	if places >= moneyPlaces {
+		return m
+	}
+	if places < 0 {
+		places = 0
+	}
+
+	unit := Money(1)
+	for i := places; i < moneyPlaces; i++ {
+		unit *= 10
+	}
+
+	q, r := m/unit, m%unit
+	switch {
+	case r*2 >= unit:
+		q++
+	case r*2 <= -unit:
+		q--
+	}
+	return q * unit
+}
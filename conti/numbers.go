@@ -0,0 +1,138 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package conti provides business logic of trial account calculation
+package conti
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// parseAmount converts a number written in the format nf, e.g. '€1.234,56'
+// or '(45.00)', into Money
+func parseAmount(s string, nf Numbers) (Money, error) {
+	var neg bool
+
+	num := strings.TrimSpace(s)
+
+	for _, c := range nf.Currency {
+		if c != "" {
+			num = strings.Replace(num, c, "", -1)
+		}
+	}
+	num = strings.TrimSpace(num)
+
+	if nf.Parentheses && strings.HasPrefix(num, "(") && strings.HasSuffix(num, ")") {
+		neg = true
+		num = strings.TrimSpace(num[1 : len(num)-1])
+	}
+
+	if nf.TrailingMinus && strings.HasSuffix(num, "-") && len(num) > 1 {
+		if neg {
+			return 0, &strconv.NumError{Func: "parseAmount", Num: s, Err: strconv.ErrSyntax}
+		}
+		neg = true
+		num = strings.TrimSpace(num[:len(num)-1])
+	}
+
+	decimal := nf.Decimal
+	if decimal == "" {
+		decimal = "."
+	}
+
+	whole, frac := num, ""
+	point := strings.Index(num, decimal)
+	if point >= 0 {
+		whole, frac = num[:point], num[point+len(decimal):]
+	}
+
+	// Thousands separators are only allowed in the integer part
+	whole, ok := nf.ungroup(whole)
+	if !ok || strings.IndexFunc(frac, nf.separates) >= 0 {
+		return 0, &strconv.NumError{Func: "parseAmount", Num: s, Err: strconv.ErrSyntax}
+	}
+
+	if decimal != "." && strings.Contains(whole+frac, ".") {
+		// A dot is neither the decimal nor a thousands separator here
+		return 0, &strconv.NumError{Func: "parseAmount", Num: s, Err: strconv.ErrSyntax}
+	}
+
+	num = whole
+	if point >= 0 {
+		num += "." + frac
+	}
+
+	m, err := ParseMoney(num)
+	if err != nil {
+		return 0, &strconv.NumError{Func: "parseAmount", Num: s, Err: err.(*strconv.NumError).Err}
+	}
+
+	if neg {
+		if m < 0 {
+			return 0, &strconv.NumError{Func: "parseAmount", Num: s, Err: strconv.ErrSyntax}
+		}
+		m = -m
+	}
+	return m, nil
+}
+
+// amountProblem describes why a value of a field, e.g. 'amount', can't be
+// read, by the error of parseAmount
+func amountProblem(field, value string, err error) string {
+	if errors.Is(err, ErrDecimalPlaces) {
+		return fmt.Sprintf("%s '%s' has more than %d decimal places", field, value, moneyPlaces)
+	}
+	return field + " '" + value + "' is not a number"
+}
+
+// ***************************************************************************
+// * METHODS
+// ***************************************************************************
+
+// separates detects whether a character is a thousands separator
+func (nf Numbers) separates(r rune) bool {
+	if strings.ContainsRune(nf.Thousands, r) {
+		return true
+	}
+	// Note: a space separator also stands for non-breaking spaces
+	return strings.ContainsRune(nf.Thousands, ' ') && (r == '\u00a0' || r == '\u202f')
+}
+
+// ungroup removes thousands separators from the integer part of a number,
+// e.g. '-1,234,567', and detects whether they are in place, i.e. whether
+// they split the digits into groups of three, but the first one
+func (nf Numbers) ungroup(whole string) (string, bool) {
+	var (
+		groups []string
+		last   int
+	)
+
+	sign := ""
+	if strings.HasPrefix(whole, "-") || strings.HasPrefix(whole, "+") {
+		sign, whole = whole[:1], whole[1:]
+	}
+
+	for i, r := range whole {
+		if nf.separates(r) {
+			groups = append(groups, whole[last:i])
+			last = i + utf8.RuneLen(r)
+		}
+	}
+	if groups == nil {
+		return sign + whole, true
+	}
+	groups = append(groups, whole[last:])
+
+	for i, g := range groups {
+		n := utf8.RuneCountInString(g)
+		if (i == 0 && (n == 0 || n > 3)) || (i > 0 && n != 3) {
+			return whole, false
+		}
+	}
+	return sign + strings.Join(groups, ""), true
+}
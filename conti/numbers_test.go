@@ -0,0 +1,52 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+package conti
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+// TestParseAmount checks amounts written in number formats, and the errors
+// of amounts which can't be read
+func TestParseAmount(t *testing.T) {
+	euro := Numbers{Decimal: ",", Thousands: ". ", Parentheses: true}
+	plain := Numbers{Thousands: ","}
+
+	tests := []struct {
+		s    string
+		nf   Numbers
+		want Money
+		err  error
+	}{
+		{"1.234,56", euro, 12345600, nil},
+		{"(1.234,00)", euro, -12340000, nil},
+		{"1 234,5", euro, 12345000, nil},
+		{"1,23450", euro, 12345, nil},
+		{"1,234.56", euro, 0, strconv.ErrSyntax},
+		{"1.23,4", euro, 0, strconv.ErrSyntax},
+		{"12.34", euro, 0, strconv.ErrSyntax},
+		{"1,23456", euro, 0, ErrDecimalPlaces},
+		{"1,234,567.5", plain, 12345675000, nil},
+		{"1,23,4.5", plain, 0, strconv.ErrSyntax},
+		{"1,234.5,6", plain, 0, strconv.ErrSyntax},
+		{"0.12345", Numbers{}, 0, ErrDecimalPlaces},
+		{"1.5e3", Numbers{}, 15000000, nil},
+		{"1_000", Numbers{}, 0, strconv.ErrSyntax},
+	}
+
+	for _, tt := range tests {
+		got, err := parseAmount(tt.s, tt.nf)
+		switch {
+		case tt.err == nil && err != nil:
+			t.Errorf("parseAmount(%q): %v", tt.s, err)
+		case tt.err != nil && !errors.Is(err, tt.err):
+			t.Errorf("parseAmount(%q) error = %v, want %v", tt.s, err, tt.err)
+		case got != tt.want:
+			t.Errorf("parseAmount(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package conti provides business logic of trial account calculation
+package conti
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ResolvePath resolves the working directory set in a template, e.g.
+// 'data', '~/books' or '$HOME/books', against the directory dir of the
+// template: environment variables are expanded, '~' stands for the home
+// directory of the user, and a relative path is relative to dir. An empty
+// path is dir itself.
+func ResolvePath(path, dir string) (string, error) {
+	var missing []string
+
+	path = os.Expand(strings.TrimSpace(path), func(name string) string {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, "'"+name+"'")
+		}
+		return value
+	})
+	if len(missing) != 0 {
+		return path, fmt.Errorf("environment variables not set: %s", strings.Join(missing, ", "))
+	}
+
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return path, err
+		}
+		path = filepath.Join(home, path[1:])
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	return filepath.Clean(path), nil
+}
+
+// RelativePath returns the working directory relative to the directory dir
+// of a template, e.g. '.' or 'data', so that the template may be moved
+// together with its files; the path is kept as is if it can't be made
+// relative, e.g. on another drive
+func RelativePath(path, dir string) string {
+	if strings.TrimSpace(path) == "" {
+		return path
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	base, err := filepath.Abs(dir)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(base, abs)
+	if err != nil {
+		return path
+	}
+	return rel
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package conti provides business logic of trial account calculation
+package conti
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Date layouts accepted in records and in the reporting period.
+// Note: numeric dates other than ISO 8601 are read as day-month-year.
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006/01/02",
+	"02.01.2006",
+	"02/01/2006",
+	"02-01-2006",
+	"2 Jan 2006",
+	"2-Jan-2006",
+	"2 January 2006",
+	"Jan 2, 2006",
+	"January 2, 2006",
+	time.RFC3339,
+}
+
+// Period represents a reporting period. Any of the bounds may be omitted.
+type Period struct {
+	// The first day of the period, e.g. '2020-01-01'
+	From string `json:"from,omitempty" yaml:"from,omitempty"`
+
+	// The last day of the period, e.g. '2020-03-31'
+	To string `json:"to,omitempty" yaml:"to,omitempty"`
+}
+
+// parseDate converts a date string in any of the accepted layouts into
+// time.Time; an empty string gives a zero time.Time
+func parseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("unrecognized date '" + s + "', use YYYY-MM-DD")
+}
+
+// calendarDay returns the midnight of the day of a time as written, in its
+// own time zone, e.g. 2020-03-31 for '2020-03-31T23:30:00-05:00'
+func calendarDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// ***************************************************************************
+// * METHODS
+// ***************************************************************************
+
+// bounds parses the period's bounds; a zero time.Time stands for an open bound
+func (p Period) bounds() (from, to time.Time, alert NoticeOfError) {
+	var err error
+
+	from, err = parseDate(p.From)
+	if err == nil {
+		to, err = parseDate(p.To)
+	}
+	if err == nil && !from.IsZero() && !to.IsZero() && to.Before(from) {
+		err = errors.New("the end of the period '" + p.To + "' is before its start '" + p.From + "'")
+	}
+	if err != nil {
+		alert = NoticeOfError{
+			Code: CaseWrongFormat,
+			Hint: "Check the reporting period in the template",
+			Err:  err,
+		}
+		alert.Trace.Crumbs("bounds")
+	}
+	return
+}
+
+// split divides records into those dated before the period, to be carried
+// into the opening balance, and those within the period. Records after the
+// period are left out. Undated records are taken as within the period.
+func (p Period) split(records []Transactions) (prior, within []Transactions, alert NoticeOfError) {
+	from, to, alert := p.bounds()
+	if alert.Err != nil {
+		alert.Trace.Crumbs("split")
+		return nil, nil, alert
+	}
+
+	// Records carried and left out per file
+	var files []string
+	carried, left := map[string]int{}, map[string]int{}
+
+	// Note: whole days are compared, so that a record with a time of day,
+	// e.g. '2020-03-31T18:30:00Z', is within a period ending on that day
+	for _, rec := range records {
+		switch {
+		case rec.Date.IsZero():
+			within = append(within, rec)
+
+		case !from.IsZero() && calendarDay(rec.Date).Before(calendarDay(from)):
+			prior = append(prior, rec)
+			if carried[rec.File]+left[rec.File] == 0 {
+				files = append(files, rec.File)
+			}
+			carried[rec.File]++
+
+		case !to.IsZero() && calendarDay(rec.Date).After(calendarDay(to)):
+			// Skip records after the period
+			if carried[rec.File]+left[rec.File] == 0 {
+				files = append(files, rec.File)
+			}
+			left[rec.File]++
+
+		default:
+			within = append(within, rec)
+		}
+	}
+
+	for _, file := range files {
+		if n := carried[file]; n != 0 {
+			alert.Diagnostics = append(alert.Diagnostics, Diagnostic{
+				Severity: SeverityInfo,
+				File:     file,
+				Message:  fmt.Sprintf("%d record(s) dated before %s carried into the opening balances", n, p.From),
+			})
+		}
+		if n := left[file]; n != 0 {
+			alert.Diagnostics = append(alert.Diagnostics, Diagnostic{
+				Severity: SeverityInfo,
+				File:     file,
+				Message:  fmt.Sprintf("%d record(s) dated after %s left out", n, p.To),
+			})
+		}
+	}
+	return prior, within, alert
+}
+
+// carryForward adds the records dated before the reporting period to the
+// opening balances of categories
+func carryForward(cats []Categories, prior []Transactions, sections []Section) ([]Categories, error) {
+	if len(prior) == 0 {
+		return cats, nil
+	}
+
+	opening, _, err := postTransactionsToAccounts(cats, prior, sections)
+	if err != nil {
+		return cats, err
+	}
+
+	for j := range opening {
+		opening[j].Bal = Tally{Sta: opening[j].Bal.End}
+	}
+	return opening, nil
+}
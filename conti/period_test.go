@@ -0,0 +1,44 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+package conti
+
+import "testing"
+
+// TestSplitTimestampOnBounds checks that records with a time of day on the
+// first and on the last day of the period are within the period
+func TestSplitTimestampOnBounds(t *testing.T) {
+	p := Period{From: "2020-01-01", To: "2020-03-31"}
+
+	var records []Transactions
+	for _, s := range []string{
+		"2019-12-31T23:59:59Z",
+		"2020-01-01T01:00:00+02:00",
+		"2020-03-31T18:30:00Z",
+		"2020-03-31T23:30:00-05:00",
+		"2020-04-01T00:00:00Z",
+	} {
+		date, err := parseDate(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, Transactions{Amount: 100, Date: date, File: "r.csv"})
+	}
+
+	prior, within, alert := p.split(records)
+	if alert.Err != nil {
+		t.Fatal(alert)
+	}
+	if len(prior) != 1 || !prior[0].Date.Equal(records[0].Date) {
+		t.Errorf("prior = %v, want the record of 2019-12-31", prior)
+	}
+	if len(within) != 3 {
+		t.Fatalf("within = %v, want 3 records", within)
+	}
+	for i, rec := range within {
+		if !rec.Date.Equal(records[i+1].Date) {
+			t.Errorf("within[%d] = %v, want %v", i, rec.Date, records[i+1].Date)
+		}
+	}
+}
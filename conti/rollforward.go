@@ -0,0 +1,139 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package conti provides business logic of trial account calculation
+package conti
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// RollForward runs ending category calculations and returns the categories
+// with the ending values as the starting values of the next period, and the
+// outcome of trial balance checks. The categories of the Profit & Loss
+// Statement are closed into the closing category set in the template, if
+// any, and start the next period with zero; without a closing category they
+// must be zero, e.g. closed by records included in the template.
+func RollForward(q Schema) ([]Categories, Verdict, NoticeOfError) {
+	var (
+		cats  []Categories
+		rep   Report
+		alert NoticeOfError
+	)
+
+	if strings.TrimSpace(q.Closing) != "" {
+		_, cats, rep, alert = AccountsClose(q)
+	} else {
+		cats, rep, alert = AccountsReport(q)
+	}
+	if alert.Err != nil {
+		alert.Trace.Crumbs("RollForward")
+		return nil, Verdict{}, alert
+	}
+
+	if err := checkClosed(cats, rep.Sections); err != nil {
+		alert = NoticeOfError{
+			Code: CaseWrongFormat,
+			Hint: "Set the category of retained earnings to close the Profit & Loss Statement into, e.g. 'closing: 320', in the template",
+			Err:  err,
+
+			Diagnostics: alert.Diagnostics,
+		}
+		alert.Trace.Crumbs("RollForward")
+		return nil, Verdict{}, alert
+	}
+
+	opening := make([]Categories, len(cats))
+	for i, c := range cats {
+		c.Bal = Tally{Sta: c.Bal.End}
+		c.Level = 0
+		opening[i] = c
+	}
+	return opening, TrialBalance(rep), alert
+}
+
+// NextSchema returns the template of the next period: the sections of the
+// template with a chart file per section in the directory dir, named by
+// ChartFileName, and no records. Rules set for the whole template are kept;
+// rules to read the chart files are set per file if the template has rules
+// of its own, as the chart files are written in the default layout.
+func NextSchema(q Schema, dir string) Schema {
+	next := Schema{
+		Version:   SchemaVersion,
+		Path:      dir,
+		Records:   []Record{},
+		Closing:   q.Closing,
+		Hierarchy: q.Hierarchy,
+		Layout:    q.Layout,
+	}
+	next.Sections = append([]Section(nil), q.Sections...)
+
+	var layout Layout
+	if !q.Layout.empty() {
+		layout = Layout{
+			Columns: Columns{Cat: "1", Name: "2", Balance: "3"},
+			Numbers: Numbers{Decimal: "."},
+			Dialect: Dialect{Delimiter: ","},
+		}
+	}
+
+	for _, s := range q.ChartSections() {
+		next.SetChartFile(s.Name, ChartFile{File: ChartFileName(s.Name), Layout: layout})
+	}
+	return next
+}
+
+// ChartFileName returns the name of the chart file of a section, e.g.
+// 'chart-contra-assets.csv' for 'Contra Assets'
+func ChartFileName(section string) string {
+	slug := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return '-'
+	}, strings.TrimSpace(section))
+
+	// Note: collapse runs of dashes
+	for strings.Contains(slug, "--") {
+		slug = strings.ReplaceAll(slug, "--", "-")
+	}
+	return "chart-" + strings.Trim(slug, "-") + ".csv"
+}
+
+// WriteChartCsv writes the categories of a section in the CSV format to w,
+// in the default column order: category, name, starting balance, followed
+// by the parent category if any category has a parent
+func WriteChartCsv(w io.Writer, cats []Categories, section string) error {
+	writer := csv.NewWriter(w)
+
+	parents := false
+	for _, c := range cats {
+		if c.Sect == section && c.Parent != "" {
+			parents = true
+		}
+	}
+
+	head := []string{"Cat", "Title", "Starting Balance"}
+	if parents {
+		head = append(head, "Parent")
+	}
+	writer.Write(head)
+
+	for _, c := range cats {
+		if c.Sect != section {
+			continue
+		}
+		field := []string{c.Cat, c.Name, c.Bal.Sta.String()}
+		if parents {
+			field = append(field, c.Parent)
+		}
+		writer.Write(field)
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
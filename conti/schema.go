@@ -6,33 +6,242 @@
 package conti
 
 import (
+	"bytes"
 	"encoding/json"
 	"net/http"
+	"reflect"
 )
 
 const Headers bool = true
 
 // Schema represents the structure from JSON received in the client request body
 type Schema struct {
+	// Version of the template format, SchemaVersion when written by Kitri
+	Version int `json:"version" yaml:"version"`
+
 	// Working directory
 	Path string `json:"path"`
 
 	// Names of CSV files containing the Chart of Accounts, a file per section
+	// Note: unless sections are declared
 	Chart struct {
-		Assets      string `json:"assets"`
-		Liabilities string `json:"liabilities"`
-		Equity      string `json:"equity"`
-		Revenues    string `json:"revenues"`
-		Expenses    string `json:"expenses"`
-	}
+		Assets      ChartFile `json:"assets"`
+		Liabilities ChartFile `json:"liabilities"`
+		Equity      ChartFile `json:"equity"`
+		Revenues    ChartFile `json:"revenues"`
+		Expenses    ChartFile `json:"expenses"`
+	} `yaml:"chart,omitempty"`
+
+	// Optional sections of the Chart of Accounts with a file per section;
+	// the five sections of the chart are the default
+	Sections []Section `json:"sections,omitempty" yaml:"sections,omitempty"`
 
 	// A list of CSV files containing records of transactions to be processed
 	Records []Record `json:"records"`
+
+	// Reporting period: records dated before the period are carried into
+	// the opening balances, records after the period are left out
+	Period Period `json:"period" yaml:"period,omitempty"`
+
+	// Optional category of retained earnings, e.g. '320', to close the
+	// categories of the Profit & Loss Statement into
+	Closing string `json:"closing,omitempty" yaml:"closing,omitempty"`
+
+	// Rule to find parents of categories: 'parent' (by the Parent column of
+	// the Chart of Accounts, the default) or 'prefix' (by the longest id of
+	// a category which is a prefix of the id)
+	Hierarchy string `json:"hierarchy,omitempty" yaml:"hierarchy,omitempty"`
+
+	// Default rules to read files, unless set per file
+	Layout `yaml:",inline"`
 }
 
 type Record struct {
-	Include int
+	// The file is read unless left out of the calculation
+	Include bool `json:"include" yaml:"include"`
 	Id      string
+
+	// Optional format of the file: records of a source and a purpose each
+	// by default, or 'journal' for entries of any number of debit and credit
+	// legs
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+
+	// Optional rules to read the file
+	Layout `yaml:",inline"`
+}
+
+// ChartFile names a CSV file of the Chart of Accounts. In a template it is
+// either a file name, e.g. 'assets: chart-assets.csv', or a mapping with
+// the file name and rules to read the file, e.g.
+//
+//	assets:
+//	  file: chart-assets.csv
+//	  columns: {cat: 1, name: Title, balance: 4}
+type ChartFile struct {
+	File string `json:"file" yaml:"file"`
+
+	// Optional rules to read the file
+	Layout `yaml:",inline"`
+}
+
+// Layout describes how data is laid out in an input file
+type Layout struct {
+	// Optional mapping of columns; the positional layout is the default
+	Columns Columns `json:"columns,omitempty" yaml:"columns,omitempty"`
+
+	// Optional number format; plain numbers like '-1234.56' are the default
+	Numbers Numbers `json:"numbers,omitempty" yaml:"numbers,omitempty"`
+
+	// Optional CSV dialect; comma-separated UTF-8 with a title row is the
+	// default
+	Dialect Dialect `json:"dialect,omitempty" yaml:"dialect,omitempty"`
+}
+
+// Columns maps data fields to the columns of a file. A column is given by
+// its 1-based index, e.g. '5', or by its title in the first row, e.g.
+// 'Amount'. Empty values stand for the default positional layout.
+type Columns struct {
+	// Records of transactions
+	Amount  Column `json:"amount,omitempty" yaml:"amount,omitempty"`
+	Source  Column `json:"source,omitempty" yaml:"source,omitempty"`
+	Purpose Column `json:"purpose,omitempty" yaml:"purpose,omitempty"`
+	Date    Column `json:"date,omitempty" yaml:"date,omitempty"`
+	Memo    Column `json:"memo,omitempty" yaml:"memo,omitempty"`
+	Ref     Column `json:"ref,omitempty" yaml:"ref,omitempty"`
+
+	// Entries of a journal
+	Entry   Column `json:"entry,omitempty" yaml:"entry,omitempty"`
+	Account Column `json:"account,omitempty" yaml:"account,omitempty"`
+	Debit   Column `json:"debit,omitempty" yaml:"debit,omitempty"`
+	Credit  Column `json:"credit,omitempty" yaml:"credit,omitempty"`
+
+	// Categories of the Chart of Accounts
+	Cat     Column `json:"cat,omitempty" yaml:"cat,omitempty"`
+	Name    Column `json:"name,omitempty" yaml:"name,omitempty"`
+	Balance Column `json:"balance,omitempty" yaml:"balance,omitempty"`
+	Parent  Column `json:"parent,omitempty" yaml:"parent,omitempty"`
+}
+
+// Column is a 1-based column index or a column title
+type Column string
+
+// Numbers describes how amounts and balances are written, e.g. '1.234,56'
+// with Decimal ',' and Thousands '.', or '(£45.00)' with Currency '£' and
+// Parentheses set
+type Numbers struct {
+	// Decimal separator, '.' by default
+	Decimal string `json:"decimal,omitempty" yaml:"decimal,omitempty"`
+
+	// Thousands separator(s), e.g. ',', '.', ' ' or "'"; none by default
+	Thousands string `json:"thousands,omitempty" yaml:"thousands,omitempty"`
+
+	// Currency symbols or codes to ignore, e.g. '€', '£' or 'EUR'
+	Currency []string `json:"currency,omitempty" yaml:"currency,omitempty"`
+
+	// Read accounting negatives in parentheses, e.g. '(45.00)', as -45.00
+	Parentheses bool `json:"parentheses,omitempty" yaml:"parentheses,omitempty"`
+
+	// Read a trailing minus, e.g. '45.00-', as -45.00
+	TrailingMinus bool `json:"trailingMinus,omitempty" yaml:"trailingMinus,omitempty"`
+}
+
+// empty detects whether no rules to read a file are set
+func (l Layout) empty() bool {
+	return reflect.DeepEqual(l, Layout{})
+}
+
+// Dialect describes how a CSV file is written, e.g. semicolon-delimited
+// Windows-1252 text saved by LibreOffice in some locales
+type Dialect struct {
+	// Field delimiter, ',' by default; 'tab' stands for a tab character
+	Delimiter string `json:"delimiter,omitempty" yaml:"delimiter,omitempty"`
+
+	// Quote character, '"' by default; 'none' if quotes are ordinary text
+	Quote string `json:"quote,omitempty" yaml:"quote,omitempty"`
+
+	// Text encoding, e.g. 'windows-1252', 'iso-8859-1' or 'utf-16';
+	// UTF-8 by default
+	Encoding string `json:"encoding,omitempty" yaml:"encoding,omitempty"`
+
+	// Keep a byte order mark (BOM) at the start of the file; it's stripped
+	// by default
+	KeepBOM bool `json:"keepBOM,omitempty" yaml:"keepBOM,omitempty"`
+
+	// The number of title rows to skip, 1 by default; the last of them is
+	// the title row for column mapping
+	Headers *int `json:"headers,omitempty" yaml:"headers,omitempty"`
+
+	// Lines starting with this character are ignored, e.g. '#'
+	Comment string `json:"comment,omitempty" yaml:"comment,omitempty"`
+}
+
+// layout returns rules to read a file, taking the template defaults for
+// rules not set for the file.
+// Note: workbooks keep numbers as values, so the template-wide number
+// format applies to CSV files only.
+func (q Schema) layout(l Layout, ref string) Layout {
+	if reflect.DeepEqual(l.Columns, Columns{}) {
+		l.Columns = q.Columns
+	}
+	if reflect.DeepEqual(l.Numbers, Numbers{}) && !isWorkbook(ref) {
+		l.Numbers = q.Numbers
+	}
+	if reflect.DeepEqual(l.Dialect, Dialect{}) {
+		l.Dialect = q.Dialect
+	}
+	return l
+}
+
+// UnmarshalYAML reads a chart file given either as a file name or as
+// a mapping
+func (c *ChartFile) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&c.File); err == nil {
+		return nil
+	}
+	type plain ChartFile
+	return unmarshal((*plain)(c))
+}
+
+// MarshalYAML writes a chart file as a file name unless rules to read the
+// file are set
+func (c ChartFile) MarshalYAML() (interface{}, error) {
+	if c.Layout.empty() {
+		return c.File, nil
+	}
+	type plain ChartFile
+	return plain(c), nil
+}
+
+// UnmarshalJSON reads a chart file given either as a file name or as
+// an object; unknown keys of the object are rejected
+func (c *ChartFile) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &c.File); err == nil {
+		return nil
+	}
+	type plain ChartFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode((*plain)(c))
+}
+
+// MarshalJSON writes a chart file as a file name unless rules to read the
+// file are set
+func (c ChartFile) MarshalJSON() ([]byte, error) {
+	if c.Layout.empty() {
+		return json.Marshal(c.File)
+	}
+	type plain ChartFile
+	return json.Marshal(plain(c))
+}
+
+// UnmarshalJSON reads a column given either as a number or as a string
+func (c *Column) UnmarshalJSON(data []byte) error {
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err == nil {
+		*c = Column(n.String())
+		return nil
+	}
+	return json.Unmarshal(data, (*string)(c))
 }
 
 // DecodeSchema parses a JSON payload from request body.
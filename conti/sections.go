@@ -6,14 +6,85 @@
 package conti
 
 import (
-	"math"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
 )
 
+// Normal balance sides of sections
+const (
+	SideDebit  = "debit"
+	SideCredit = "credit"
+)
+
+// Statements showing sections
+const (
+	StatementBalance    = "balance"
+	StatementProfit     = "profit"
+	StatementOffBalance = "off-balance"
+)
+
+// Section describes a section of the Chart of Accounts declared in the
+// template, e.g.
+//
+//	sections:
+//	- name: Contra Assets
+//	  side: credit
+//	  statement: balance
+//	  total: Assets
+//	  file: chart-contra.csv
+type Section struct {
+	// Name of the section, e.g. 'Assets'
+	Name string `json:"name" yaml:"name"`
+
+	// Normal balance side: 'debit' or 'credit'
+	Side string `json:"side" yaml:"side"`
+
+	// Statement showing the section: 'balance' (the Balance Sheet), 'profit'
+	// (the Profit & Loss Statement) or 'off-balance' (memorandum accounts
+	// left out of the accounting identities)
+	Statement string `json:"statement" yaml:"statement"`
+
+	// Optional name of a section of the same statement whose total includes
+	// this one, e.g. 'Assets' for 'Contra Assets'; the values of a section
+	// of the other side are subtracted
+	Total string `json:"total,omitempty" yaml:"total,omitempty"`
+
+	// Shows the retained result in the section, as in 'Equity'
+	Retained bool `json:"retained,omitempty" yaml:"retained,omitempty"`
+
+	// File of the section
+	File string `json:"file" yaml:"file"`
+
+	// Optional rules to read the file
+	// Note: not an embedded ChartFile, whose methods to read a file name
+	// alone would be promoted to the section
+	Layout `yaml:",inline"`
+}
+
+// Report represents total values per section of the Balance Sheet and of
+// the Profit & Loss Statement
 type Report struct {
 	Balance BalanceSections
 	Profit  ProfitSections
+
+	// Sections of the Chart of Accounts in the order of the template
+	Sections []Section
+
+	// Totals per section by the name of the section, each in the sign of
+	// the normal balance of the section
+	Totals map[string]Tally
+
+	// The amounts posted to the debit and to the credit of categories,
+	// added up record by record as posted
+	Debits  Money
+	Credits Money
 }
 
+// BalanceSections keeps the totals of the Balance Sheet: Assets add up
+// the debit sections, Liabilities the credit sections and Equity the credit
+// sections showing the retained result
 type BalanceSections struct {
 	Assets Tally
 	Liabls Tally
@@ -24,125 +95,276 @@ type BalanceSections struct {
 	Retained Tally
 }
 
+// ProfitSections keeps the totals of the Profit & Loss Statement: Revenue
+// adds up the credit sections and Expense the debit sections
 type ProfitSections struct {
 	Revenue Tally
 	Expense Tally
 	Profit  Tally
 }
 
-// specialSection detects if category's section requires special treatment
-func specialSection(csec map[string]string, cat string) bool {
-	if s, ok := csec[cat]; ok {
-		// Found
-		// Special sections:
-		if s == "Revenues" || s == "Liabilities" || s == "Equity" {
-			return true
-		} else {
-			return false
+// checkSections checks that the sections declared in the template have
+// unique names, known sides and statements, and totals in sections of the
+// same statement
+func checkSections(q Schema) NoticeOfError {
+	var (
+		alert NoticeOfError
+		lines []string
+	)
+
+	if len(q.Sections) == 0 {
+		return alert
+	}
+
+	if !reflect.DeepEqual(q.Chart, Schema{}.Chart) {
+		alert = NoticeOfError{
+			Code: CaseWrongFormat,
+			Hint: "Set files of the Chart of Accounts either in 'chart' or in 'sections' of the template",
+			Err:  errors.New("both chart and sections are set in the template"),
 		}
+		alert.Trace.Crumbs("checkSections")
+		return alert
 	}
-	return false
-}
 
-// startBal calculates the total balance value per section at the beginning
-func (this *Report) startBal(sval map[string]float64, csec map[string]string, cat string) {
-	if value, ok := sval[cat]; ok {
-		if section, ok := csec[cat]; ok {
-			switch {
-			case section == "Assets":
-				this.Balance.Assets.Sta += value
+	sections := q.ChartSections()
+	byName := map[string]Section{}
+	retained := 0
+	for _, s := range sections {
+		if s.Name == "" {
+			lines = append(lines, "A section has no name")
+			continue
+		}
+		if _, ok := byName[s.Name]; ok {
+			lines = append(lines, fmt.Sprintf("Section '%s': the name is not unique", s.Name))
+		}
+		byName[s.Name] = s
 
-			case section == "Liabilities":
-				this.Balance.Liabls.Sta += value
+		if s.Side != SideDebit && s.Side != SideCredit {
+			lines = append(lines, fmt.Sprintf("Section '%s': side '%s' is neither '%s' nor '%s'", s.Name, s.Side, SideDebit, SideCredit))
+		}
+		switch s.Statement {
+		case StatementBalance, StatementProfit, StatementOffBalance:
+		default:
+			lines = append(lines, fmt.Sprintf("Section '%s': statement '%s' is neither '%s', '%s' nor '%s'",
+				s.Name, s.Statement, StatementBalance, StatementProfit, StatementOffBalance))
+		}
+		if s.Retained {
+			retained++
+			if s.Statement != StatementBalance || s.Total != "" {
+				lines = append(lines, fmt.Sprintf("Section '%s': only a section of the balance sheet with no total may show the retained result", s.Name))
+			}
+			if s.Side != SideCredit {
+				lines = append(lines, fmt.Sprintf("Section '%s': the retained result may only be shown in a section of side '%s'", s.Name, SideCredit))
+			}
+		}
+	}
 
-			case section == "Equity":
-				this.Balance.Equity.Sta += value
+	if retained > 1 {
+		lines = append(lines, "The retained result may be shown in one section only")
+	}
 
-			case section == "Assets" || section == "Liabilities" || section == "Equity":
-				this.Balance.Retained.Sta += value
+	for _, s := range sections {
+		if s.Total == "" {
+			continue
+		}
+		t, ok := byName[s.Total]
+		switch {
+		case !ok || t.Name == s.Name:
+			lines = append(lines, fmt.Sprintf("Section '%s': total '%s' is not another section", s.Name, s.Total))
+		case t.Statement != s.Statement:
+			lines = append(lines, fmt.Sprintf("Section '%s': total '%s' is in another statement", s.Name, s.Total))
+		case t.Total != "":
+			lines = append(lines, fmt.Sprintf("Section '%s': total '%s' is itself in the total of '%s'", s.Name, s.Total, t.Total))
+		}
+	}
 
-			case section == "Revenues":
-				this.Profit.Revenue.Sta += value
+	if len(lines) == 0 {
+		return alert
+	}
 
-			case section == "Expenses":
-				this.Profit.Expense.Sta += value
+	alert = NoticeOfError{
+		Code: CaseWrongFormat,
+		Hint: "Correct the sections in the template:\n" + strings.Join(lines, "\n"),
+		Err:  fmt.Errorf("%d wrong setting(s) of sections", len(lines)),
+	}
+	for _, line := range lines {
+		alert.Diagnostics = append(alert.Diagnostics, Diagnostic{
+			Severity: SeverityError,
+			Code:     CaseWrongFormat,
+			Message:  line,
+		})
+	}
+	alert.Trace.Crumbs("checkSections")
+	return alert
+}
 
-			default:
-				// Do nothing
-			}
-		}
+// sectionSides builds a section-side map
+func sectionSides(sections []Section) map[string]string {
+	sides := map[string]string{}
+	for _, s := range sections {
+		sides[s.Name] = s.Side
 	}
+	return sides
 }
 
-// changeBal calculates the difference between the ending and the starting values per section
-func (this *Report) changeBal(csec map[string]string, cat string, value float64) {
-	if section, ok := csec[cat]; ok {
-		switch {
-		case section == "Assets":
-			this.Balance.Assets.Dif += value
+// creditSide detects if the category's section has a normal credit balance,
+// so that a record crediting the category adds to its value
+func creditSide(csec, sides map[string]string, cat string) bool {
+	if s, ok := csec[cat]; ok {
+		// Found
+		return sides[s] == SideCredit
+	}
+	return false
+}
 
-		case section == "Liabilities":
-			this.Balance.Liabls.Dif += value
+// groups returns the sections of a statement with no total of another
+// section, i.e. the sections shown with subtotals
+func groups(sections []Section, statement string) []Section {
+	var out []Section
+	for _, s := range sections {
+		if s.Statement == statement && s.Total == "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
 
-		case section == "Equity":
-			this.Balance.Equity.Dif += value
+// members returns the sections whose values are included in the total of
+// the named section
+func members(sections []Section, name string) []Section {
+	var out []Section
+	for _, s := range sections {
+		if s.Total == name {
+			out = append(out, s)
+		}
+	}
+	return out
+}
 
-		case section == "Revenues":
-			this.Profit.Revenue.Dif += value
-			this.Profit.Profit.Dif += value
+// sectionNames returns the names of the sections
+func sectionNames(sections []Section) []string {
+	names := make([]string, len(sections))
+	for i, s := range sections {
+		names[i] = s.Name
+	}
+	return names
+}
 
-		case section == "Expenses":
-			this.Profit.Expense.Dif += value
-			this.Profit.Profit.Dif -= value
+// ***************************************************************************
+// * METHODS
+// ***************************************************************************
 
-		default:
-			// Do nothing
+// ChartSections returns the sections of the Chart of Accounts declared in
+// the template or, if none are declared, the five sections of the chart:
+// Assets, Liabilities, Equity, Revenues and Expenses
+func (q Schema) ChartSections() []Section {
+	if len(q.Sections) == 0 {
+		return []Section{
+			{Name: "Assets", Side: SideDebit, Statement: StatementBalance, File: q.Chart.Assets.File, Layout: q.Chart.Assets.Layout},
+			{Name: "Liabilities", Side: SideCredit, Statement: StatementBalance, File: q.Chart.Liabilities.File, Layout: q.Chart.Liabilities.Layout},
+			{Name: "Equity", Side: SideCredit, Statement: StatementBalance, Retained: true, File: q.Chart.Equity.File, Layout: q.Chart.Equity.Layout},
+			{Name: "Revenues", Side: SideCredit, Statement: StatementProfit, File: q.Chart.Revenues.File, Layout: q.Chart.Revenues.Layout},
+			{Name: "Expenses", Side: SideDebit, Statement: StatementProfit, File: q.Chart.Expenses.File, Layout: q.Chart.Expenses.Layout},
 		}
 	}
-}
-
-// finalBal calculates the total balance value per section at the end
-func (this *Report) finalBal() {
-	this.Balance.Assets.End = this.Balance.Assets.Sta + this.Balance.Assets.Dif
-	this.Balance.Liabls.End = this.Balance.Liabls.Sta + this.Balance.Liabls.Dif
-	this.Balance.Equity.End = this.Balance.Equity.Sta + this.Balance.Equity.Dif
 
-	this.Profit.Revenue.End = this.Profit.Revenue.Sta + this.Profit.Revenue.Dif
-	this.Profit.Expense.End = this.Profit.Expense.Sta + this.Profit.Expense.Dif
+	sections := make([]Section, len(q.Sections))
+	for i, s := range q.Sections {
+		s.Name = strings.TrimSpace(s.Name)
+		s.Side = strings.ToLower(strings.TrimSpace(s.Side))
+		s.Statement = strings.ToLower(strings.TrimSpace(s.Statement))
+		s.Total = strings.TrimSpace(s.Total)
+		sections[i] = s
+	}
+	return sections
+}
 
-	this.Profit.Profit.End = this.Profit.Profit.Sta + this.Profit.Profit.Dif
+// SetChartFile replaces the file of a section, either declared in the
+// template or one of the five sections of the chart
+func (q *Schema) SetChartFile(name string, cf ChartFile) {
+	if len(q.Sections) != 0 {
+		for i := range q.Sections {
+			if strings.TrimSpace(q.Sections[i].Name) == name {
+				q.Sections[i].File = cf.File
+				q.Sections[i].Layout = cf.Layout
+			}
+		}
+		return
+	}
 
-	this.Balance.Retained.Dif = this.Profit.Profit.Dif
-	this.Balance.Retained.End = this.Balance.Retained.Sta + this.Balance.Retained.Dif
+	switch name {
+	case "Assets":
+		q.Chart.Assets = cf
+	case "Liabilities":
+		q.Chart.Liabilities = cf
+	case "Equity":
+		q.Chart.Equity = cf
+	case "Revenues":
+		q.Chart.Revenues = cf
+	case "Expenses":
+		q.Chart.Expenses = cf
+	}
 }
 
-// roundBal rounds the balance change and the ending balance to the required
-// number of decimals
-func (this *Report) roundBal() {
-	this.Balance.Assets.Sta = math.Round(this.Balance.Assets.Sta*decimals) / decimals
-	this.Balance.Liabls.Sta = math.Round(this.Balance.Liabls.Sta*decimals) / decimals
-	this.Balance.Equity.Sta = math.Round(this.Balance.Equity.Sta*decimals) / decimals
+// ChartFile returns the file of the section and rules to read the file
+func (s Section) ChartFile() ChartFile {
+	return ChartFile{File: s.File, Layout: s.Layout}
+}
 
-	this.Profit.Revenue.Sta = math.Round(this.Profit.Revenue.Sta*decimals) / decimals
-	this.Profit.Expense.Sta = math.Round(this.Profit.Expense.Sta*decimals) / decimals
-	this.Profit.Profit.Sta = math.Round(this.Profit.Profit.Sta*decimals) / decimals
+// sumSections adds up the values of categories per section
+func (this *Report) sumSections(cats []Categories) {
+	this.Totals = map[string]Tally{}
+	for _, s := range this.Sections {
+		this.Totals[s.Name] = Tally{}
+	}
+	for _, c := range cats {
+		this.Totals[c.Sect] = this.Totals[c.Sect].plus(c.Bal)
+	}
+}
 
-	this.Balance.Assets.End = math.Round(this.Balance.Assets.End*decimals) / decimals
-	this.Balance.Liabls.End = math.Round(this.Balance.Liabls.End*decimals) / decimals
-	this.Balance.Equity.End = math.Round(this.Balance.Equity.End*decimals) / decimals
+// groupTotal returns the total of a section and of the sections included in
+// its total; values of sections of the other side are subtracted
+func (this *Report) groupTotal(g Section) Tally {
+	sum := this.Totals[g.Name]
+	for _, m := range members(this.Sections, g.Name) {
+		if m.Side == g.Side {
+			sum = sum.plus(this.Totals[m.Name])
+		} else {
+			sum = sum.minus(this.Totals[m.Name])
+		}
+	}
+	return sum
+}
 
-	this.Profit.Revenue.End = math.Round(this.Profit.Revenue.End*decimals) / decimals
-	this.Profit.Expense.End = math.Round(this.Profit.Expense.End*decimals) / decimals
-	this.Profit.Profit.End = math.Round(this.Profit.Profit.End*decimals) / decimals
+// finalBal calculates the totals of the Balance Sheet and of the Profit &
+// Loss Statement from the totals per section
+func (this *Report) finalBal() {
+	for _, g := range groups(this.Sections, StatementBalance) {
+		switch {
+		case g.Side == SideDebit:
+			this.Balance.Assets = this.Balance.Assets.plus(this.groupTotal(g))
+		case g.Retained:
+			this.Balance.Equity = this.Balance.Equity.plus(this.groupTotal(g))
+		default:
+			this.Balance.Liabls = this.Balance.Liabls.plus(this.groupTotal(g))
+		}
+	}
 
-	this.Balance.Assets.Dif = math.Round(this.Balance.Assets.Dif*decimals) / decimals
-	this.Balance.Liabls.Dif = math.Round(this.Balance.Liabls.Dif*decimals) / decimals
-	this.Balance.Equity.Dif = math.Round(this.Balance.Equity.Dif*decimals) / decimals
+	for _, g := range groups(this.Sections, StatementProfit) {
+		if g.Side == SideCredit {
+			this.Profit.Revenue = this.Profit.Revenue.plus(this.groupTotal(g))
+		} else {
+			this.Profit.Expense = this.Profit.Expense.plus(this.groupTotal(g))
+		}
+	}
 
-	this.Profit.Revenue.Dif = math.Round(this.Profit.Revenue.Dif*decimals) / decimals
-	this.Profit.Expense.Dif = math.Round(this.Profit.Expense.Dif*decimals) / decimals
-	this.Profit.Profit.Dif = math.Round(this.Profit.Profit.Dif*decimals) / decimals
+	// Note: the starting profit (loss) doesn't reflect the actual starting
+	// P/L value, as the retained profit or the loss carried forward is in
+	// 'Equity, P&L Account' with a user-defined key. The starting P/L
+	// values, if any, are a result not yet carried to 'Equity, P&L Account'.
+	this.Profit.Profit = this.Profit.Revenue.minus(this.Profit.Expense)
 
-	this.Balance.Retained.Dif = math.Round(this.Balance.Retained.Dif*decimals) / decimals
-	this.Balance.Retained.End = math.Round(this.Balance.Retained.End*decimals) / decimals
+	this.Balance.Retained.Sta = this.Profit.Profit.Sta
+	this.Balance.Retained.Dif = this.Profit.Profit.Dif
+	this.Balance.Retained.End = this.Balance.Retained.Sta + this.Balance.Retained.Dif
 }
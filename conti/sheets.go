@@ -0,0 +1,540 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package conti provides business logic of trial account calculation
+package conti
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Namespaces of OpenDocument spreadsheet elements and attributes
+const (
+	odsTable  = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	odsText   = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+	odsOffice = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+)
+
+// splitSheet splits a file reference like 'book.xlsx#Assets' into the file
+// name and the sheet name; the sheet name is empty if not provided
+func splitSheet(ref string) (file, sheet string) {
+	if i := strings.LastIndex(ref, "#"); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, ""
+}
+
+// isWorkbook reports whether a file reference points to a spreadsheet
+// workbook rather than to a CSV file
+func isWorkbook(ref string) bool {
+	file, _ := splitSheet(ref)
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".xlsx", ".ods":
+		return true
+	default:
+		return false
+	}
+}
+
+// Sheets lists the sheets of a .xlsx or .ods workbook; the list is empty for
+// other files
+func Sheets(filename string) ([]string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".xlsx":
+		f, err := excelize.OpenFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return f.GetSheetList(), nil
+
+	case ".ods":
+		zr, err := zip.OpenReader(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+
+		for _, zf := range zr.File {
+			if zf.Name != "content.xml" {
+				continue
+			}
+			rc, err := zf.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			return odsSheets(rc)
+		}
+		return nil, errors.New("no content.xml in " + filepath.Base(filename))
+
+	default:
+		return nil, nil
+	}
+}
+
+// readFile reads a CSV file or a sheet of a workbook into a [][]string
+// matrix
+func readFile(ref string, d Dialect) ([][]string, NoticeOfError) {
+	file, sheet := splitSheet(ref)
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".xlsx":
+		return readFileXlsx(file, sheet)
+
+	case ".ods":
+		return readFileOds(file, sheet)
+
+	default:
+		return readFileCsv(ref, d)
+	}
+}
+
+// readFileXlsx reads a sheet of an Excel workbook into a [][]string matrix;
+// the first sheet is read if no sheet name is provided.
+// Note: numbers are taken as stored, regardless of the number format of
+// cells, with 15 significant digits (see numberCell), and dates are
+// converted into the 'YYYY-MM-DD' form.
+func readFileXlsx(filename, sheet string) ([][]string, NoticeOfError) {
+	var (
+		alert NoticeOfError
+		mx    [][]string
+	)
+
+	f, err := excelize.OpenFile(filename)
+	if err != nil {
+		alert = NoticeOfError{
+			Code:     CaseNotFound,
+			Resource: filename,
+			Hint:     "File not found: " + filename,
+			Err:      err,
+		}
+		if !os.IsNotExist(err) {
+			alert.Code = CaseUnreadable
+			alert.Hint = "Failed to open the workbook: " + filename
+		}
+		alert.Trace.Crumbs("readFileXlsx")
+		return mx, alert
+	}
+	defer f.Close()
+
+	sheet, err = pickSheet(f.GetSheetList(), sheet)
+	if err != nil {
+		alert = NoticeOfError{
+			Code:     CaseNotFound,
+			Resource: filename,
+			Hint:     "Check the sheet name set for '" + filepath.Base(filename) + "' in the template",
+			Err:      err,
+		}
+		alert.Trace.Crumbs("readFileXlsx")
+		return mx, alert
+	}
+
+	mx, err = f.GetRows(sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		alert = NoticeOfError{
+			Code:     CaseUnreadable,
+			Resource: filename + "#" + sheet,
+			Hint:     "Failed to read the sheet '" + sheet + "' of " + filename,
+			Err:      err,
+		}
+		alert.Trace.Crumbs("readFileXlsx")
+		return mx, alert
+	}
+
+	props, _ := f.GetWorkbookProps()
+	date1904 := props.Date1904 != nil && *props.Date1904
+
+	// Cache of styles which format dates
+	dated := map[int]bool{}
+
+	for i := range mx {
+		for j, v := range mx[i] {
+			serial, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+
+			// Note: text cells, e.g. '010', are kept as they are
+			axis, _ := excelize.CoordinatesToCellName(j+1, i+1)
+			kind, err := f.GetCellType(sheet, axis)
+			if err != nil || (kind != excelize.CellTypeUnset && kind != excelize.CellTypeNumber) {
+				continue
+			}
+			mx[i][j] = numberCell(serial)
+
+			id, err := f.GetCellStyle(sheet, axis)
+			if err != nil || id == 0 {
+				continue
+			}
+			isDate, ok := dated[id]
+			if !ok {
+				if style, err := f.GetStyle(id); err == nil {
+					isDate = dateFormat(style.NumFmt, style.CustomNumFmt)
+				}
+				dated[id] = isDate
+			}
+			if !isDate {
+				continue
+			}
+
+			if t, err := excelize.ExcelDateToTime(serial, date1904); err == nil {
+				mx[i][j] = t.Format("2006-01-02")
+			}
+		}
+	}
+	return mx, alert
+}
+
+// numberCell formats a number stored in a workbook with 15 significant
+// digits, as spreadsheets show it, so that the noise of binary floating
+// point, e.g. 0.30000000000000004 computed for '=0.1+0.2', is left out
+func numberCell(v float64) string {
+	return strconv.FormatFloat(v, 'g', 15, 64)
+}
+
+// dateFormat reports whether a number format of a cell displays a date
+func dateFormat(id int, custom *string) bool {
+	if custom != nil {
+		return dateCode(*custom)
+	}
+	// Note: built-in date formats, including Asian ones
+	switch {
+	case id >= 14 && id <= 17, id == 22:
+		return true
+	case id >= 27 && id <= 31, id >= 34 && id <= 36:
+		return true
+	case id >= 50 && id <= 58, id >= 71 && id <= 76, id == 81:
+		return true
+	default:
+		return false
+	}
+}
+
+// dateCode reports whether a custom number format code has day, month or
+// year placeholders outside quoted text and brackets
+func dateCode(code string) bool {
+	var quoted, bracket, escaped bool
+
+	for _, r := range strings.ToLower(code) {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted:
+			quoted = r != '"'
+		case bracket:
+			bracket = r != ']'
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = true
+		case r == '[':
+			bracket = true
+		case r == 'd', r == 'm', r == 'y':
+			return true
+		case r == ';':
+			// Only the format of positive numbers matters
+			return false
+		}
+	}
+	return false
+}
+
+// readFileOds reads a sheet of an OpenDocument spreadsheet into a [][]string
+// matrix; the first sheet is read if no sheet name is provided.
+// Note: numbers are taken as stored, regardless of the number format of
+// cells, and dates are taken in the 'YYYY-MM-DD' form.
+func readFileOds(filename, sheet string) ([][]string, NoticeOfError) {
+	var (
+		alert NoticeOfError
+		mx    [][]string
+	)
+
+	zr, err := zip.OpenReader(filename)
+	if err != nil {
+		alert = NoticeOfError{
+			Code:     CaseNotFound,
+			Resource: filename,
+			Hint:     "File not found: " + filename,
+			Err:      err,
+		}
+		if !os.IsNotExist(err) {
+			alert.Code = CaseUnreadable
+			alert.Hint = "Failed to open the spreadsheet: " + filename
+		}
+		alert.Trace.Crumbs("readFileOds")
+		return mx, alert
+	}
+	defer zr.Close()
+
+	var content *zip.File
+	for _, zf := range zr.File {
+		if zf.Name == "content.xml" {
+			content = zf
+			break
+		}
+	}
+	if content == nil {
+		alert = NoticeOfError{
+			Code:     CaseUnreadable,
+			Resource: filename,
+			Hint:     "Not an OpenDocument spreadsheet: " + filename,
+			Err:      errors.New("no content.xml in " + filepath.Base(filename)),
+		}
+		alert.Trace.Crumbs("readFileOds")
+		return mx, alert
+	}
+
+	rc, err := content.Open()
+	if err == nil {
+		mx, err = odsTableRows(rc, sheet)
+		rc.Close()
+	}
+	if err != nil {
+		alert = NoticeOfError{
+			Code:     CaseUnreadable,
+			Resource: filename,
+			Hint:     "Failed to read the spreadsheet: " + filename + "; check the sheet name set in the template",
+			Err:      err,
+		}
+		alert.Trace.Crumbs("readFileOds")
+		return mx, alert
+	}
+	return mx, alert
+}
+
+// odsTableRows reads the rows of a table (sheet) from the content of an
+// OpenDocument spreadsheet; the first table is read if no name is provided.
+// Trailing empty rows and cells are left out.
+func odsTableRows(r io.Reader, sheet string) ([][]string, error) {
+	var (
+		mx     [][]string
+		row    []string
+		cell   strings.Builder
+		names  []string
+		inside bool // within the table to read
+		found  bool
+		inCell bool
+		skip   int // depth of nested elements to ignore, e.g. annotations
+		value  string
+		rows   int // repeat count of the row
+		cols   int // repeat count of the cell
+		blank  int // pending empty rows
+		gap    int // pending empty cells
+	)
+
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skip > 0 {
+				skip++
+				continue
+			}
+			switch {
+			case t.Name.Space == odsTable && t.Name.Local == "table":
+				name := odsAttr(t, odsTable, "name")
+				names = append(names, name)
+				if !found && (sheet == "" || sheet == name) {
+					inside, found = true, true
+				}
+
+			case !inside:
+				// Skip other tables
+
+			case t.Name.Space == odsTable && t.Name.Local == "table-row":
+				row, gap = nil, 0
+				rows = odsRepeat(t, "number-rows-repeated")
+
+			case t.Name.Space == odsTable && (t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell"):
+				inCell = true
+				cell.Reset()
+				value = odsValue(t)
+				cols = odsRepeat(t, "number-columns-repeated")
+
+			case t.Name.Space == odsOffice && t.Name.Local == "annotation":
+				skip = 1
+
+			case inCell && t.Name.Space == odsText && t.Name.Local == "p":
+				if cell.Len() > 0 {
+					cell.WriteString("\n")
+				}
+
+			case inCell && t.Name.Space == odsText && t.Name.Local == "s":
+				n := odsRepeat(t, "c")
+				cell.WriteString(strings.Repeat(" ", n))
+
+			case inCell && t.Name.Space == odsText && t.Name.Local == "tab":
+				cell.WriteString("\t")
+
+			case inCell && t.Name.Space == odsText && t.Name.Local == "line-break":
+				cell.WriteString("\n")
+			}
+
+		case xml.CharData:
+			if inCell && skip == 0 {
+				cell.Write(t)
+			}
+
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if !inside {
+				continue
+			}
+			switch {
+			case t.Name.Space == odsTable && t.Name.Local == "table":
+				inside = false
+
+			case t.Name.Space == odsTable && (t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell"):
+				inCell = false
+				v := value
+				if v == "" {
+					v = cell.String()
+				}
+				if v == "" {
+					// Note: empty cells are added only if followed by a value
+					gap += cols
+					continue
+				}
+				for ; gap > 0; gap-- {
+					row = append(row, "")
+				}
+				for k := 0; k < cols; k++ {
+					row = append(row, v)
+				}
+
+			case t.Name.Space == odsTable && t.Name.Local == "table-row":
+				if len(row) == 0 {
+					// Note: empty rows are added only if followed by a row
+					// with values
+					blank += rows
+					continue
+				}
+				for ; blank > 0; blank-- {
+					mx = append(mx, nil)
+				}
+				for k := 0; k < rows; k++ {
+					mx = append(mx, append([]string(nil), row...))
+				}
+			}
+		}
+	}
+
+	if !found {
+		return nil, errors.New("no sheet '" + sheet + "' in the spreadsheet; sheets: " + strings.Join(names, ", "))
+	}
+	return mx, nil
+}
+
+// odsSheets lists the names of tables (sheets) in the content of an
+// OpenDocument spreadsheet
+func odsSheets(r io.Reader) ([]string, error) {
+	var names []string
+
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return names, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		t, ok := tok.(xml.StartElement)
+		if ok && t.Name.Space == odsTable && t.Name.Local == "table" {
+			names = append(names, odsAttr(t, odsTable, "name"))
+			// Note: the contents of the table aren't needed
+			if err := dec.Skip(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// odsValue returns the value of an OpenDocument cell stored as a number,
+// a date or a boolean; it's empty for text cells
+func odsValue(t xml.StartElement) string {
+	switch odsAttr(t, odsOffice, "value-type") {
+	case "float", "percentage", "currency":
+		v := odsAttr(t, odsOffice, "value")
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return numberCell(f)
+		}
+		return v
+
+	case "date":
+		v := odsAttr(t, odsOffice, "date-value")
+		if len(v) > len("2006-01-02") {
+			v = v[:len("2006-01-02")]
+		}
+		return v
+
+	case "boolean":
+		return odsAttr(t, odsOffice, "boolean-value")
+
+	default:
+		return ""
+	}
+}
+
+// odsRepeat returns the repeat count set in a table attribute, 1 by default
+func odsRepeat(t xml.StartElement, local string) int {
+	space := odsTable
+	if t.Name.Space == odsText {
+		space = odsText
+	}
+	n, err := strconv.Atoi(odsAttr(t, space, local))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// odsAttr returns the value of an element's attribute
+func odsAttr(t xml.StartElement, space, local string) string {
+	for _, a := range t.Attr {
+		if a.Name.Space == space && a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// pickSheet returns the name of the sheet to read: the provided one, if it
+// exists, or the first sheet
+func pickSheet(sheets []string, sheet string) (string, error) {
+	if len(sheets) == 0 {
+		return "", errors.New("no sheets in the workbook")
+	}
+	if sheet == "" {
+		return sheets[0], nil
+	}
+	for _, s := range sheets {
+		if s == sheet {
+			return s, nil
+		}
+	}
+	return "", errors.New("no sheet '" + sheet + "' in the workbook; sheets: " + strings.Join(sheets, ", "))
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+package conti
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// TestReadFileXlsxFloatNoise checks that numbers computed in a workbook are
+// read with no binary noise, and that text cells are kept as they are
+func TestReadFileXlsxFloatNoise(t *testing.T) {
+	a, b, price := 0.1, 0.2, 0.805
+
+	f := excelize.NewFile()
+	f.SetCellStr("Sheet1", "A1", "Amount")
+	f.SetCellFloat("Sheet1", "A2", a+b, -1, 64)
+	f.SetCellFloat("Sheet1", "A3", 10*price, -1, 64)
+	f.SetCellStr("Sheet1", "A4", "010")
+	f.SetCellStr("Sheet1", "A5", "0.30000000000000004")
+
+	name := filepath.Join(t.TempDir(), "noise.xlsx")
+	if err := f.SaveAs(name); err != nil {
+		t.Fatal(err)
+	}
+
+	mx, alert := readFileXlsx(name, "")
+	if alert.Err != nil {
+		t.Fatal(alert)
+	}
+
+	want := [][]string{{"Amount"}, {"0.3"}, {"8.05"}, {"010"}, {"0.30000000000000004"}}
+	if !reflect.DeepEqual(mx, want) {
+		t.Fatalf("readFileXlsx() = %q, want %q", mx, want)
+	}
+
+	for _, v := range []string{mx[1][0], mx[2][0]} {
+		if _, err := parseAmount(v, Numbers{}); err != nil {
+			t.Errorf("parseAmount(%q): %v", v, err)
+		}
+	}
+}
+
+// TestOdsTableRowsFloatNoise checks that numbers stored in an OpenDocument
+// spreadsheet are read with no binary noise
+func TestOdsTableRowsFloatNoise(t *testing.T) {
+	content := `<office:document-content
+ xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+ xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+ xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">
+<office:body><office:spreadsheet><table:table table:name="Sheet1">
+<table:table-row><table:table-cell office:value-type="string"><text:p>Amount</text:p></table:table-cell></table:table-row>
+<table:table-row><table:table-cell office:value-type="float" office:value="0.30000000000000004"><text:p>0.3</text:p></table:table-cell></table:table-row>
+<table:table-row><table:table-cell office:value-type="currency" office:value="8.0500000000000007"><text:p>€8.05</text:p></table:table-cell></table:table-row>
+</table:table></office:spreadsheet></office:body>
+</office:document-content>`
+
+	mx, err := odsTableRows(strings.NewReader(content), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{{"Amount"}, {"0.3"}, {"8.05"}}
+	if !reflect.DeepEqual(mx, want) {
+		t.Fatalf("odsTableRows() = %q, want %q", mx, want)
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package conti provides business logic of trial account calculation
+package conti
+
+import "strings"
+
+// Kinds of lines of a statement
+const (
+	LineHeading  = "heading"  // Title of a section
+	LineItem     = "item"     // A category or another single value
+	LineSubtotal = "subtotal" // Sum of the top items since the last heading
+	LineTotal    = "total"    // Sum and difference of subtotals
+)
+
+// Keys of lines referred to by totals
+// Note: subtotals of sections are keyed by sectionKey
+const (
+	keyRetained     = "retained"
+	keyRetainedSect = "retained-section"
+	keyDebits       = "debits"
+	keyCredits      = "credits"
+	keyNetAssets    = "net-assets"
+	keyProfit       = "profit"
+)
+
+// Statement represents a financial statement, such as the Balance Sheet or
+// the Profit & Loss Statement
+type Statement struct {
+	// Title of the statement
+	Title string
+
+	// Lines in the order of output
+	Lines []Line
+}
+
+// Line represents a line of a statement
+type Line struct {
+	// Kind of the line, e.g. LineItem
+	Kind string
+
+	// Optional key to refer to the line, e.g. from a total
+	Key string
+
+	// Category id; empty for lines other than categories
+	Cat string
+
+	// Section of a category
+	Sect string
+
+	// Depth of a category in the hierarchy, 0 for top categories
+	Level int
+
+	// Category name or the title of the line
+	Name string
+
+	// Starting, change and ending values; not used by headings
+	Value Tally
+
+	// Keys of the lines added up into a total and subtracted from it; for
+	// an item of a section included in the total of another section, the
+	// name of the section
+	Plus, Minus []string
+}
+
+// Statements builds the Balance Sheet, the Profit & Loss Statement and, if
+// any sections are off the balance sheet, the Off-Balance Sheet from the
+// posted categories and the section totals. Categories are rolled up and
+// shown to depth levels of the hierarchy, all levels if depth is 0.
+func Statements(cats []Categories, rep Report, depth int) []Statement {
+	stmts := []Statement{
+		BalanceSheet(cats, rep, depth),
+		ProfitAndLoss(cats, rep, depth),
+	}
+	if len(groups(rep.Sections, StatementOffBalance)) != 0 {
+		stmts = append(stmts, OffBalance(cats, rep, depth))
+	}
+	return stmts
+}
+
+// BalanceSheet builds the Balance Sheet: sections of the debit side (assets)
+// against sections of the credit side (liabilities and equity), the latter
+// including the retained result
+func BalanceSheet(cats []Categories, rep Report, depth int) Statement {
+	var debits, credits, liabls []string
+
+	b := rep.Balance
+	s := Statement{Title: "Balance Sheet"}
+	cats = Collapse(RollUp(cats), depth)
+
+	retained := false
+	for _, g := range groups(rep.Sections, StatementBalance) {
+		if g.Side == SideDebit {
+			s.section(g, rep, cats)
+			debits = append(debits, g.Name)
+		}
+	}
+	if len(debits) > 1 {
+		s.total(keyDebits, debits, sectionKeys(debits), b.Assets)
+	}
+
+	for _, g := range groups(rep.Sections, StatementBalance) {
+		if g.Side == SideCredit {
+			s.section(g, rep, cats)
+			credits = append(credits, g.Name)
+			if g.Retained {
+				retained = true
+			} else {
+				liabls = append(liabls, g.Name)
+			}
+		}
+	}
+	keys := sectionKeys(credits)
+
+	// Note: with no section to show the retained result in, it is shown
+	// apart
+	if !retained {
+		s.Lines = append(s.Lines, Line{Kind: LineHeading, Name: "Retained Result"})
+		s.retained(b.Retained)
+		s.subtotal("Total Retained Result", keyRetainedSect, b.Retained)
+		credits = append(credits, "Retained Result")
+		keys = append(keys, keyRetainedSect)
+	}
+	s.total(keyCredits, credits, keys, b.Liabls.plus(b.Equity).plus(b.Retained))
+
+	s.Lines = append(s.Lines, Line{
+		Kind:  LineTotal,
+		Key:   keyNetAssets,
+		Name:  "Net Assets",
+		Value: b.Assets.minus(b.Liabls),
+		Plus:  sectionKeys(debits),
+		Minus: sectionKeys(liabls),
+	})
+	return s
+}
+
+// ProfitAndLoss builds the Profit & Loss Statement: sections of the credit
+// side (revenues) and of the debit side (expenses) and the profit (loss)
+func ProfitAndLoss(cats []Categories, rep Report, depth int) Statement {
+	var plus, minus []string
+
+	s := Statement{Title: "Profit & Loss Statement"}
+	cats = Collapse(RollUp(cats), depth)
+
+	for _, g := range groups(rep.Sections, StatementProfit) {
+		s.section(g, rep, cats)
+		if g.Side == SideCredit {
+			plus = append(plus, sectionKey(g.Name))
+		} else {
+			minus = append(minus, sectionKey(g.Name))
+		}
+	}
+
+	s.Lines = append(s.Lines, Line{
+		Kind:  LineTotal,
+		Key:   keyProfit,
+		Name:  "Profit (Loss)",
+		Value: rep.Profit.Profit,
+		Plus:  plus,
+		Minus: minus,
+	})
+	return s
+}
+
+// OffBalance builds the Off-Balance Sheet: sections of memorandum accounts
+// left out of the accounting identities
+func OffBalance(cats []Categories, rep Report, depth int) Statement {
+	s := Statement{Title: "Off-Balance Sheet"}
+	cats = Collapse(RollUp(cats), depth)
+
+	for _, g := range groups(rep.Sections, StatementOffBalance) {
+		s.section(g, rep, cats)
+	}
+	return s
+}
+
+// sectionKey returns the key of the subtotal of a section
+func sectionKey(name string) string {
+	return "section:" + name
+}
+
+// sectionKeys returns the keys of the subtotals of sections
+func sectionKeys(names []string) []string {
+	keys := make([]string, len(names))
+	for i, name := range names {
+		keys[i] = sectionKey(name)
+	}
+	return keys
+}
+
+// joinNames joins names as in 'Liabilities, Equity and Retained Result'
+func joinNames(names []string) string {
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+// ***************************************************************************
+// * METHODS
+// ***************************************************************************
+
+// section adds a heading and the categories of a section, arranged as a
+// tree, followed by the sections included in its total, the retained
+// result if shown in the section and a subtotal
+func (s *Statement) section(g Section, rep Report, cats []Categories) {
+	s.Lines = append(s.Lines, Line{Kind: LineHeading, Name: g.Name})
+	s.categories(g.Name, cats, 0)
+
+	for _, m := range members(rep.Sections, g.Name) {
+		line := Line{
+			Kind:  LineItem,
+			Sect:  m.Name,
+			Name:  m.Name,
+			Value: rep.Totals[m.Name],
+			Plus:  []string{m.Name},
+		}
+		if m.Side != g.Side {
+			line.Name = "Less: " + m.Name
+			line.Value = Tally{}.minus(line.Value)
+			line.Plus, line.Minus = nil, []string{m.Name}
+		}
+		s.Lines = append(s.Lines, line)
+		s.categories(m.Name, cats, 1)
+	}
+
+	value := rep.groupTotal(g)
+	if g.Retained {
+		s.retained(rep.Balance.Retained)
+		value = value.plus(rep.Balance.Retained)
+	}
+	s.subtotal("Total "+g.Name, sectionKey(g.Name), value)
+}
+
+// categories adds the categories of a section, arranged as a tree, shifted
+// by levels of the hierarchy
+func (s *Statement) categories(sect string, cats []Categories, shift int) {
+	for _, c := range cats {
+		if c.Sect != sect {
+			continue
+		}
+		s.Lines = append(s.Lines, Line{
+			Kind:  LineItem,
+			Cat:   c.Cat,
+			Sect:  c.Sect,
+			Level: c.Level + shift,
+			Name:  c.Name,
+			Value: c.Bal,
+		})
+	}
+}
+
+// retained adds the retained result
+func (s *Statement) retained(value Tally) {
+	s.Lines = append(s.Lines, Line{
+		Kind:  LineItem,
+		Key:   keyRetained,
+		Name:  "Retained Result",
+		Value: value,
+	})
+}
+
+// subtotal adds a subtotal of the top items since the last heading
+func (s *Statement) subtotal(name, key string, value Tally) {
+	s.Lines = append(s.Lines, Line{
+		Kind:  LineSubtotal,
+		Key:   key,
+		Name:  name,
+		Value: value,
+	})
+}
+
+// total adds a total of the subtotals of the named sections
+func (s *Statement) total(key string, names, keys []string, value Tally) {
+	s.Lines = append(s.Lines, Line{
+		Kind:  LineTotal,
+		Key:   key,
+		Name:  "Total " + joinNames(names),
+		Value: value,
+		Plus:  keys,
+	})
+}
+
+// plus returns the sum of tallies
+func (t Tally) plus(u Tally) Tally {
+	return Tally{Sta: t.Sta + u.Sta, Dif: t.Dif + u.Dif, End: t.End + u.End}
+}
+
+// minus returns the difference of tallies
+func (t Tally) minus(u Tally) Tally {
+	return Tally{Sta: t.Sta - u.Sta, Dif: t.Dif - u.Dif, End: t.End - u.End}
+}
+
+// column returns the starting (0), change (1) or ending (2) value
+func (t Tally) column(i int) Money {
+	switch i {
+	case 0:
+		return t.Sta
+	case 1:
+		return t.Dif
+	default:
+		return t.End
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package conti provides business logic of trial account calculation
+package conti
+
+import (
+	"fmt"
+	"strings"
+)
+
+// validateTransactions checks that every record refers to categories known
+// from the Chart of Accounts. All offending records are listed in the hint,
+// so that the calculation never produces numbers that are silently wrong.
+func validateTransactions(cats []Categories, records []Transactions) NoticeOfError {
+	var (
+		alert NoticeOfError
+		files []string
+		lines []string
+		diags []Diagnostic
+	)
+
+	cSec := catSec(cats)
+	seen := map[string]bool{}
+
+	for _, rec := range records {
+		for _, side := range []struct {
+			role, cat string
+			col       int
+		}{
+			{"source", rec.Source, rec.cols[0]},
+			{"purpose", rec.Purpose, rec.cols[1]},
+		} {
+			if _, ok := cSec[side.cat]; ok {
+				continue
+			}
+			row, raw := rec.origin(side.role)
+			lines = append(lines, fmt.Sprintf("File '%s', row %d: unknown %s category '%s'%s",
+				rec.File, row, side.role, side.cat, raw))
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Code:     CaseCategoryNotKnown,
+				File:     rec.File,
+				Row:      row,
+				Column:   side.col,
+				Message:  "unknown " + side.role + " category '" + side.cat + "'",
+			})
+			if !seen[rec.File] {
+				seen[rec.File] = true
+				files = append(files, rec.File)
+			}
+		}
+	}
+
+	if len(lines) == 0 {
+		return alert
+	}
+
+	alert = NoticeOfError{
+		Code:     CaseCategoryNotKnown,
+		Resource: strings.Join(files, ", "),
+		Hint:     "Add the categories to the Chart of Accounts or correct the records:\n" + strings.Join(lines, "\n"),
+		Err:      fmt.Errorf("%d reference(s) to unknown categories", len(lines)),
+
+		Diagnostics: diags,
+	}
+	alert.Trace.Crumbs("validateTransactions")
+	return alert
+}
+
+// ***************************************************************************
+// * METHODS
+// ***************************************************************************
+
+// origin returns the number of the row the source or the purpose of the
+// record is read from, and the row as read, e.g. ' in "12.50,110,400"', to
+// point to the cell to fix; for a record decomposed from a journal entry,
+// the row of the leg
+func (rec Transactions) origin(role string) (int, string) {
+	row, raw := rec.Row, rec.Raw
+	if rec.legs != nil {
+		leg := rec.legs.purpose
+		if role == "source" {
+			leg = rec.legs.source
+		}
+		row, raw = leg.row, leg.raw
+	}
+
+	if len(raw) == 0 {
+		return row, ""
+	}
+	return row, " in \"" + strings.Join(raw, ",") + "\""
+}
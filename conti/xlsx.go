@@ -0,0 +1,444 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package conti provides business logic of trial account calculation
+package conti
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Names of sheets of the output workbook
+const (
+	sheetBalance    = "Balance Sheet"
+	sheetProfit     = "P&L"
+	sheetOffBalance = "Off-Balance"
+
+	// The longest name of a sheet allowed by Excel
+	maxSheetName = 31
+)
+
+// xlsxStyles keeps style IDs of the output workbook
+type xlsxStyles struct {
+	title, head, money, total int
+}
+
+// TO DO: TRACE errors!!!
+// ExportAccountsToXlsx writes results of value-by-category calculations in
+// an Excel workbook
+func ExportAccountsToXlsx(conti []Categories, rep Report, depth int, filename string) {
+	xlsxNewFile, err := os.Create(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error (creating file %s): %s\n", filename, err)
+		return
+	}
+	defer xlsxNewFile.Close()
+
+	err = WriteAccountsXlsx(xlsxNewFile, conti, rep, depth)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error (writing file %s): %s\n", filename, err)
+	}
+}
+
+// WriteAccountsXlsx writes results of value-by-category calculations as an
+// Excel workbook to w: the Balance Sheet, the P&L, the Off-Balance Sheet if
+// any sections are off the balance sheet, and a sheet per section.
+// Values of the statements refer to category values, and subtotals and
+// totals are SUM formulas, so that the numbers may be audited in a
+// spreadsheet. Categories are rolled up and shown to depth levels of the
+// hierarchy, all levels if depth is 0.
+func WriteAccountsXlsx(w io.Writer, conti []Categories, rep Report, depth int) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	st, err := newXlsxStyles(f)
+	if err != nil {
+		return err
+	}
+
+	// The Balance Sheet and the P&L followed by sheets per section
+	offBalance := len(groups(rep.Sections, StatementOffBalance)) != 0
+	sheets := []string{sheetProfit}
+	if offBalance {
+		sheets = append(sheets, sheetOffBalance)
+	}
+	names := sectionSheets(rep.Sections)
+	for _, section := range sectionNames(rep.Sections) {
+		sheets = append(sheets, names[section])
+	}
+
+	if err := f.SetSheetName("Sheet1", sheetBalance); err != nil {
+		return err
+	}
+	for _, sheet := range sheets {
+		if _, err := f.NewSheet(sheet); err != nil {
+			return err
+		}
+	}
+
+	// The rows of categories and of section totals are kept to refer to
+	rows := map[string]int{}
+	tree := Collapse(RollUp(conti), depth)
+	for _, section := range sectionNames(rep.Sections) {
+		if err := writeSectionSheet(f, st, names[section], section, tree, rows); err != nil {
+			return err
+		}
+	}
+
+	if offBalance {
+		_, err := writeStatementSheet(f, st, sheetOffBalance, OffBalance(conti, rep, depth), nil, rows, names, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Note: the retained result of the Balance Sheet refers to the profit
+	// (loss) of the P&L
+	profit, err := writeStatementSheet(f, st, sheetProfit, ProfitAndLoss(conti, rep, depth), nil, rows, names, nil)
+	if err != nil {
+		return err
+	}
+
+	verdict := TrialBalance(rep)
+	banner := []string{verdict.Banner()}
+	for _, d := range verdict.Imbalances {
+		banner = append(banner, d.String())
+	}
+	links := map[string]xlsxRef{
+		keyRetained: {sheet: sheetProfit, row: profit[keyProfit]},
+	}
+	_, err = writeStatementSheet(f, st, sheetBalance, BalanceSheet(conti, rep, depth), banner, rows, names, links)
+	if err != nil {
+		return err
+	}
+
+	// Formulas are calculated by a spreadsheet when the workbook is opened
+	full := true
+	if err := f.SetCalcProps(&excelize.CalcPropsOptions{FullCalcOnLoad: &full}); err != nil {
+		return err
+	}
+	f.SetActiveSheet(0)
+
+	return f.Write(w)
+}
+
+// newXlsxStyles registers styles of the output workbook
+func newXlsxStyles(f *excelize.File) (xlsxStyles, error) {
+	var (
+		st  xlsxStyles
+		err error
+	)
+
+	// Note: built-in format 4 is '#,##0.00'
+	if st.title, err = f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true, Size: 14}}); err != nil {
+		return st, err
+	}
+	if st.head, err = f.NewStyle(&excelize.Style{
+		Font:   &excelize.Font{Bold: true},
+		Border: []excelize.Border{{Type: "bottom", Color: "000000", Style: 1}},
+	}); err != nil {
+		return st, err
+	}
+	if st.money, err = f.NewStyle(&excelize.Style{NumFmt: 4}); err != nil {
+		return st, err
+	}
+	st.total, err = f.NewStyle(&excelize.Style{
+		NumFmt: 4,
+		Font:   &excelize.Font{Bold: true},
+		Border: []excelize.Border{{Type: "top", Color: "000000", Style: 1}},
+	})
+	return st, err
+}
+
+// writeSectionSheet writes the categories of a section to its sheet,
+// arranged as a tree, with a row of totals and keeps the rows of categories
+// by section and category id, and the row of totals by section. The values of a parent are its own values plus the values
+// of its children shown.
+func writeSectionSheet(f *excelize.File, st xlsxStyles, sheet, section string, tree []Categories, rows map[string]int) error {
+	var (
+		cats []Categories
+		tops []int
+	)
+
+	if err := f.SetSheetRow(sheet, "A1", &[]interface{}{"Cat", "Name", "Starting", "Change", "Ending"}); err != nil {
+		return err
+	}
+	f.SetCellStyle(sheet, "A1", "E1", st.head)
+
+	for _, c := range tree {
+		if c.Sect == section {
+			cats = append(cats, c)
+		}
+	}
+
+	// Note: categories start in row 2, following the title row
+	for i, c := range cats {
+		row := i + 2
+		rows[c.Sect+"/"+c.Cat] = row
+		if c.Level == 0 {
+			tops = append(tops, row)
+		}
+
+		f.SetCellStr(sheet, cellName(1, row), c.Cat)
+		f.SetCellStr(sheet, cellName(2, row), indent(c.Level)+c.Name)
+
+		children := childRows(cats, i)
+		own := c.Bal
+		for _, j := range children {
+			own = own.minus(cats[j].Bal)
+		}
+
+		for col := 3; col <= 4; col++ {
+			value := own.column(col - 3)
+			if len(children) == 0 {
+				f.SetCellFloat(sheet, cellName(col, row), value.Float64(), -1, 64)
+				continue
+			}
+
+			formula := ""
+			if value != 0 {
+				formula = value.String()
+			}
+			for _, j := range children {
+				if formula != "" {
+					formula += "+"
+				}
+				formula += cellName(col, j+2)
+			}
+			if err := f.SetCellFormula(sheet, cellName(col, row), formula); err != nil {
+				return err
+			}
+		}
+		if err := f.SetCellFormula(sheet, cellName(5, row), fmt.Sprintf("C%d+D%d", row, row)); err != nil {
+			return err
+		}
+	}
+	f.SetCellStyle(sheet, "C2", cellName(5, len(cats)+1), st.money)
+
+	total := len(cats) + 2
+	rows[section+"/"] = total
+	f.SetCellStr(sheet, cellName(2, total), "Total")
+	for col := 3; col <= 5; col++ {
+		if err := f.SetCellFormula(sheet, cellName(col, total), sumRows(col, tops)); err != nil {
+			return err
+		}
+	}
+	f.SetCellStyle(sheet, cellName(1, total), cellName(5, total), st.total)
+
+	f.SetColWidth(sheet, "B", "B", 48)
+	f.SetColWidth(sheet, "C", "E", 14)
+	return nil
+}
+
+// sumRows returns a SUM formula of cells of a column in the rows: a range if
+// the rows are adjacent, a list otherwise
+func sumRows(col int, rows []int) string {
+	if len(rows) == 0 {
+		return "0"
+	}
+	if rows[len(rows)-1]-rows[0] == len(rows)-1 {
+		return fmt.Sprintf("SUM(%s:%s)", cellName(col, rows[0]), cellName(col, rows[len(rows)-1]))
+	}
+
+	formula := ""
+	for _, row := range rows {
+		if formula != "" {
+			formula += ","
+		}
+		formula += cellName(col, row)
+	}
+	return "SUM(" + formula + ")"
+}
+
+// xlsxRef refers to a row of a sheet
+type xlsxRef struct {
+	sheet string
+	row   int
+}
+
+// writeStatementSheet writes a statement headed with optional banner lines.
+// Categories refer to the sheets per section (rows, and the sheets named by
+// section in names), items without a category refer to links or take the
+// values of the statement. The rows of
+// keyed lines are returned to refer to.
+func writeStatementSheet(f *excelize.File, st xlsxStyles, sheet string, stmt Statement, banner []string,
+	rows map[string]int, names map[string]string, links map[string]xlsxRef) (map[string]int, error) {
+	keys := map[string]int{}
+
+	f.SetCellStr(sheet, "A1", stmt.Title)
+	f.SetCellStyle(sheet, "A1", "A1", st.title)
+
+	row := 1
+	for _, line := range banner {
+		row++
+		f.SetCellStr(sheet, cellName(1, row), line)
+	}
+
+	row += 2
+	f.SetSheetRow(sheet, cellName(1, row), &[]interface{}{"Cat", "Name", "Starting", "Change", "Ending"})
+	f.SetCellStyle(sheet, cellName(1, row), cellName(5, row), st.head)
+
+	// Rows of the top items since the last heading
+	var tops []int
+	for _, line := range stmt.Lines {
+		row++
+		if line.Key != "" {
+			keys[line.Key] = row
+		}
+
+		switch line.Kind {
+		case LineHeading:
+			f.SetCellStr(sheet, cellName(1, row), line.Name)
+			f.SetCellStyle(sheet, cellName(1, row), cellName(1, row), st.head)
+			tops = nil
+			continue
+
+		case LineItem:
+			if line.Level == 0 {
+				tops = append(tops, row)
+			}
+			f.SetCellStr(sheet, cellName(1, row), line.Cat)
+			f.SetCellStr(sheet, cellName(2, row), indent(line.Level)+line.Name)
+			f.SetCellStyle(sheet, cellName(3, row), cellName(5, row), st.money)
+
+		default:
+			f.SetCellStr(sheet, cellName(2, row), line.Name)
+			f.SetCellStyle(sheet, cellName(2, row), cellName(5, row), st.total)
+		}
+
+		for col := 3; col <= 5; col++ {
+			formula := statementFormula(line, col, tops, keys, rows, names, links)
+			if formula == "" {
+				f.SetCellFloat(sheet, cellName(col, row), line.Value.column(col-3).Float64(), -1, 64)
+				continue
+			}
+			if err := f.SetCellFormula(sheet, cellName(col, row), formula); err != nil {
+				return keys, err
+			}
+		}
+	}
+
+	f.SetColWidth(sheet, "A", "A", 12)
+	f.SetColWidth(sheet, "B", "B", 48)
+	f.SetColWidth(sheet, "C", "E", 14)
+	return keys, nil
+}
+
+// statementFormula returns a formula for a value of a statement line in the
+// column col, or an empty string if the value is to be written as is
+func statementFormula(line Line, col int, tops []int, keys, rows map[string]int, names map[string]string, links map[string]xlsxRef) string {
+	switch line.Kind {
+	case LineItem:
+		if r, ok := rows[line.Sect+"/"+line.Cat]; ok && line.Cat != "" {
+			return sheetCell(names[line.Sect], col, r)
+		}
+		if ref, ok := links[line.Key]; ok {
+			return sheetCell(ref.sheet, col, ref.row)
+		}
+
+		// Totals of sections included in the total of another section
+		var formula string
+		for _, sect := range line.Plus {
+			if formula != "" {
+				formula += "+"
+			}
+			formula += sheetCell(names[sect], col, rows[sect+"/"])
+		}
+		for _, sect := range line.Minus {
+			formula += "-" + sheetCell(names[sect], col, rows[sect+"/"])
+		}
+		return formula
+
+	case LineSubtotal:
+		if len(tops) == 0 {
+			// No items
+			return ""
+		}
+		return sumRows(col, tops)
+
+	case LineTotal:
+		var formula string
+		for _, key := range line.Plus {
+			if formula != "" {
+				formula += "+"
+			}
+			formula += cellName(col, keys[key])
+		}
+		for _, key := range line.Minus {
+			formula += "-" + cellName(col, keys[key])
+		}
+		return formula
+
+	default:
+		return ""
+	}
+}
+
+// sectionSheets names the sheets per section by section: characters not
+// allowed in sheet names are replaced, long names are cut to maxSheetName
+// characters, and names taken by the statements or by other sections (in
+// any case) are numbered, e.g. 'Assets (2)'
+func sectionSheets(sections []Section) map[string]string {
+	names := map[string]string{}
+	taken := map[string]bool{}
+	for _, sheet := range []string{sheetBalance, sheetProfit, sheetOffBalance} {
+		taken[strings.ToLower(sheet)] = true
+	}
+
+	for _, section := range sectionNames(sections) {
+		if _, ok := names[section]; ok {
+			continue
+		}
+
+		base := strings.Map(func(r rune) rune {
+			if strings.ContainsRune(`:\/?*[]`, r) {
+				return '-'
+			}
+			return r
+		}, section)
+		// Note: a sheet name may neither start nor end with an apostrophe
+		base = strings.Trim(base, "' ")
+		if base == "" {
+			base = "Section"
+		}
+
+		sheet := cutSheetName(base, "")
+		for n := 2; taken[strings.ToLower(sheet)]; n++ {
+			sheet = cutSheetName(base, " ("+strconv.Itoa(n)+")")
+		}
+		taken[strings.ToLower(sheet)] = true
+		names[section] = sheet
+	}
+	return names
+}
+
+// cutSheetName returns a sheet name made of base and suffix, cutting base so
+// that the name has maxSheetName characters at most
+func cutSheetName(base, suffix string) string {
+	room := maxSheetName - utf8.RuneCountInString(suffix)
+	if utf8.RuneCountInString(base) > room {
+		base = strings.TrimRight(string([]rune(base)[:room]), "' ")
+	}
+	return base + suffix
+}
+
+// sheetCell returns a reference to a cell of another sheet, e.g.
+// "'Assets'!C4"
+func sheetCell(sheet string, col, row int) string {
+	return "'" + strings.ReplaceAll(sheet, "'", "''") + "'!" + cellName(col, row)
+}
+
+// cellName returns the name of a cell by its column and row numbers
+// (1-based), e.g. 'C4'
+func cellName(col, row int) string {
+	name, _ := excelize.CoordinatesToCellName(col, row)
+	return name
+}
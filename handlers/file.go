@@ -6,47 +6,436 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
-	// "fmt"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil" // to read files
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"gopkg.in/yaml.v2"
 
 	"github.com/serdug/kitri/conti"
 )
 
-// ReadSchemaJSON parses a JSON schema file
-func ReadSchemaJSON(filename string) conti.Schema {
-	var s conti.Schema
-	// fmt.Println("\nConfig file read:", filename)
+// yamlLine matches a problem reported by the YAML decoder at a line, e.g.
+// 'line 5: field colums not found in type conti.Layout'
+var yamlLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
 
-	// read the config file
-	dat, err := ioutil.ReadFile(filename)
-	if err != nil {
-		// Handle error
+// yamlField matches an unknown key reported by the YAML decoder
+var yamlField = regexp.MustCompile(`^field (\S+) not found in type \S+$`)
+
+// ReadSchemaJSON parses a JSON schema file. A template of an older version
+// is upgraded to conti.SchemaVersion, with a note of the upgrade. Unknown
+// keys are rejected, and the files of the chart sections and the list of
+// records are required; a problem is located by the line and the column of
+// the file. The working directory is resolved against the directory of the
+// file.
+func ReadSchemaJSON(filename string) (conti.Schema, conti.NoticeOfError) {
+	var (
+		s   conti.Schema
+		doc map[string]interface{}
+	)
+
+	dat, alert := readSchemaFile(filename)
+	if alert.Err != nil {
+		alert.Trace.Crumbs("ReadSchemaJSON")
+		return s, alert
+	}
+
+	if err := json.Unmarshal(dat, &doc); err != nil {
+		alert = wrongSchema(filename, []conti.Diagnostic{jsonProblem(filename, dat, err, false)})
+		alert.Trace.Crumbs("ReadSchemaJSON")
+		return s, alert
+	}
+
+	notes, alert := migrate(filename, doc)
+	if alert.Err != nil {
+		alert.Trace.Crumbs("ReadSchemaJSON")
+		return s, alert
+	}
+
+	// Note: an upgraded template is decoded as upgraded
+	src := dat
+	if len(notes) != 0 {
+		src, _ = json.Marshal(doc)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(src))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		alert = wrongSchema(filename, []conti.Diagnostic{jsonProblem(filename, dat, err, len(notes) != 0)})
+		alert.Trace.Crumbs("ReadSchemaJSON")
+		return conti.Schema{}, alert
+	}
+
+	// Note: keys of JSON objects match the fields in any case
+	present := map[string]bool{}
+	for k := range doc {
+		present[strings.ToLower(k)] = true
+	}
+
+	diags := missingSettings(filename, s, present)
+	diags = append(diags, resolvePath(filename, &s)...)
+	if len(diags) != 0 {
+		alert = wrongSchema(filename, diags)
+		alert.Trace.Crumbs("ReadSchemaJSON")
+		return conti.Schema{}, alert
+	}
+	alert.Diagnostics = notes
+	return s, alert
+}
+
+// ReadSchemaYAML parses a YAML schema file. A template of an older version
+// is upgraded to conti.SchemaVersion, with a note of the upgrade. Unknown
+// keys are rejected, and the files of the chart sections and the list of
+// records are required; a problem is located by the line of the file, and
+// an unknown key by the column too. The working directory is resolved
+// against the directory of the file.
+func ReadSchemaYAML(filename string) (conti.Schema, conti.NoticeOfError) {
+	var (
+		s   conti.Schema
+		doc map[string]interface{}
+	)
+
+	dat, alert := readSchemaFile(filename)
+	if alert.Err != nil {
+		alert.Trace.Crumbs("ReadSchemaYAML")
+		return s, alert
+	}
+
+	if err := yaml.Unmarshal(dat, &doc); err != nil {
+		alert = wrongSchema(filename, yamlProblems(filename, dat, err, false))
+		alert.Trace.Crumbs("ReadSchemaYAML")
+		return s, alert
+	}
+
+	notes, alert := migrate(filename, doc)
+	if alert.Err != nil {
+		alert.Trace.Crumbs("ReadSchemaYAML")
+		return s, alert
+	}
+
+	// Note: an upgraded template is decoded as upgraded
+	src := dat
+	if len(notes) != 0 {
+		src, _ = yaml.Marshal(doc)
+	}
+
+	if err := yaml.UnmarshalStrict(src, &s); err != nil {
+		alert = wrongSchema(filename, yamlProblems(filename, dat, err, len(notes) != 0))
+		alert.Trace.Crumbs("ReadSchemaYAML")
+		return conti.Schema{}, alert
+	}
+
+	present := map[string]bool{}
+	for k := range doc {
+		present[k] = true
+	}
+
+	diags := missingSettings(filename, s, present)
+	diags = append(diags, resolvePath(filename, &s)...)
+	if len(diags) != 0 {
+		alert = wrongSchema(filename, diags)
+		alert.Trace.Crumbs("ReadSchemaYAML")
+		return conti.Schema{}, alert
 	}
+	alert.Diagnostics = notes
+	return s, alert
+}
+
+// WriteSchemaYAML serializes and writes a schema template into a YAML file;
+// the working directory is written relative to the directory of the file
+// where possible
+func WriteSchemaYAML(filename string, s conti.Schema) error {
+	s.Path = conti.RelativePath(s.Path, filepath.Dir(filename))
 
-	err = json.Unmarshal(dat, &s)
+	// Serialize schema into a YAML document
+	data, err := yaml.Marshal(s)
 	if err != nil {
-		// Handle error
+		return err
 	}
-	return s
+
+	return ioutil.WriteFile(filename, data, 0644)
 }
 
-// ReadSchemaYAML parses a YAML schema file
-func ReadSchemaYAML(filename string) conti.Schema {
-	var s conti.Schema
-	// fmt.Println("\nConfig file read:", filename)
+// readSchemaFile reads a template file
+func readSchemaFile(filename string) ([]byte, conti.NoticeOfError) {
+	var alert conti.NoticeOfError
 
-	// read the config file
 	dat, err := ioutil.ReadFile(filename)
 	if err != nil {
-		// Handle error
+		alert = conti.NoticeOfError{
+			Code:     conti.CaseUnreadable,
+			Resource: filename,
+			Hint:     "Check that the template '" + filename + "' may be read",
+			Err:      err,
+		}
+		if os.IsNotExist(err) {
+			alert.Code = conti.CaseNotFound
+			alert.Hint = "Check the name of the template '" + filename + "'"
+		}
+		alert.Trace.Crumbs("readSchemaFile")
+	}
+	return dat, alert
+}
+
+// migrate upgrades a template document to conti.SchemaVersion; a note is
+// returned if the template is upgraded
+func migrate(filename string, doc map[string]interface{}) ([]conti.Diagnostic, conti.NoticeOfError) {
+	var alert conti.NoticeOfError
+
+	// Note: an empty file gives no document
+	if doc == nil {
+		return nil, alert
+	}
+
+	from, err := conti.MigrateSchema(doc)
+	if err != nil {
+		alert = wrongSchema(filename, []conti.Diagnostic{{
+			Severity: conti.SeverityError,
+			Code:     conti.CaseWrongFormat,
+			File:     filename,
+			Message:  err.Error(),
+		}})
+		alert.Trace.Crumbs("migrate")
+		return nil, alert
+	}
+
+	if from == conti.SchemaVersion {
+		return nil, alert
+	}
+	return []conti.Diagnostic{{
+		Severity: conti.SeverityInfo,
+		Code:     conti.CaseWrongFormat,
+		File:     filename,
+		Message:  fmt.Sprintf("the template of version %d is read as version %d; save it to keep the upgrade", from, conti.SchemaVersion),
+	}}, alert
+}
+
+// wrongSchema returns a notice of the problems found in a template
+func wrongSchema(filename string, diags []conti.Diagnostic) conti.NoticeOfError {
+	lines := make([]string, len(diags))
+	for i, d := range diags {
+		switch {
+		case d.Row > 0 && d.Column > 0:
+			lines[i] = fmt.Sprintf("Line %d, column %d: %s", d.Row, d.Column, d.Message)
+		case d.Row > 0:
+			lines[i] = fmt.Sprintf("Line %d: %s", d.Row, d.Message)
+		default:
+			lines[i] = capitalize(d.Message)
+		}
+	}
+
+	alert := conti.NoticeOfError{
+		Code:        diags[0].Code,
+		Resource:    filename,
+		Hint:        "Correct the template '" + filename + "':\n" + strings.Join(lines, "\n"),
+		Err:         fmt.Errorf("%d problem(s) in template '%s'", len(diags), filename),
+		Diagnostics: diags,
+	}
+	alert.Trace.Crumbs("wrongSchema")
+	return alert
+}
+
+// capitalize returns a message starting with a capital letter
+func capitalize(msg string) string {
+	r, size := utf8.DecodeRuneInString(msg)
+	if size == 0 {
+		return msg
+	}
+	return string(unicode.ToUpper(r)) + msg[size:]
+}
+
+// missingSettings checks that the template sets a file per section of the
+// Chart of Accounts and lists records; an empty list, e.g. 'records: []',
+// is accepted, as records may be added later
+func missingSettings(filename string, s conti.Schema, keys map[string]bool) []conti.Diagnostic {
+	var diags []conti.Diagnostic
+
+	missing := func(msg string) {
+		diags = append(diags, conti.Diagnostic{
+			Severity: conti.SeverityError,
+			Code:     conti.CaseMissingSetting,
+			File:     filename,
+			Message:  msg,
+		})
+	}
+
+	for _, sect := range s.ChartSections() {
+		if strings.TrimSpace(sect.File) != "" {
+			continue
+		}
+		if len(s.Sections) == 0 {
+			missing("no file of the chart section '" + strings.ToLower(sect.Name) + "'")
+		} else {
+			missing("no file of the section '" + sect.Name + "'")
+		}
 	}
 
-	err = yaml.Unmarshal(dat, &s)
+	if !keys["records"] {
+		missing("no list of records; add 'records' with the files of records, or an empty list to add them later")
+	}
+	return diags
+}
+
+// resolvePath resolves the working directory of the template against the
+// directory of the file, see conti.ResolvePath
+func resolvePath(filename string, s *conti.Schema) []conti.Diagnostic {
+	dir := filepath.Dir(filename)
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+
+	path, err := conti.ResolvePath(s.Path, dir)
 	if err != nil {
-		// Handle error
+		return []conti.Diagnostic{{
+			Severity: conti.SeverityError,
+			Code:     conti.CaseWrongFormat,
+			File:     filename,
+			Message:  "path '" + s.Path + "': " + err.Error(),
+		}}
+	}
+	s.Path = path
+	return nil
+}
+
+// yamlProblems locates the problems reported by the YAML decoder in the
+// file dat; the decoder reports all wrong values and unknown keys at once.
+// The lines of an upgraded template differ from those of the file, so only
+// unknown keys are located, by their first occurrence in the file.
+func yamlProblems(filename string, dat []byte, err error, upgraded bool) []conti.Diagnostic {
+	var (
+		diags []conti.Diagnostic
+		msgs  []string
+	)
+
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		msgs = typeErr.Errors
+	} else {
+		msgs = []string{err.Error()}
+	}
+
+	for _, msg := range msgs {
+		d := conti.Diagnostic{
+			Severity: conti.SeverityError,
+			Code:     conti.CaseWrongFormat,
+			File:     filename,
+			Message:  strings.TrimPrefix(msg, "yaml: "),
+		}
+		if m := yamlLine.FindStringSubmatch(msg); m != nil {
+			d.Row, _ = strconv.Atoi(m[1])
+			d.Message = m[2]
+		}
+		if upgraded {
+			d.Row = 0
+		}
+		if m := yamlField.FindStringSubmatch(d.Message); m != nil {
+			d.Code = conti.CaseUnknownSetting
+			d.Message = "unknown key '" + m[1] + "'"
+			if upgraded {
+				d.Row = keyLine(dat, m[1])
+			}
+			d.Column = keyColumn(dat, d.Row, m[1])
+		}
+		diags = append(diags, d)
+	}
+	return diags
+}
+
+// jsonProblem locates the problem reported by the JSON decoder in the file
+// dat; the decoder stops at the first one. The offsets of an upgraded
+// template differ from those of the file, so only an unknown key is
+// located, by its first occurrence in the file.
+func jsonProblem(filename string, dat []byte, err error, upgraded bool) conti.Diagnostic {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		offset    int64 = -1
+	)
+
+	d := conti.Diagnostic{
+		Severity: conti.SeverityError,
+		Code:     conti.CaseWrongFormat,
+		File:     filename,
+		Message:  strings.TrimPrefix(err.Error(), "json: "),
+	}
+
+	switch {
+	case errors.Is(err, io.EOF):
+		d.Message = "the template is empty"
+
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+		if typeErr.Field != "" {
+			d.Message = fmt.Sprintf("'%s': %s where %s is expected", typeErr.Field, typeErr.Value, typeErr.Type)
+		}
+
+	case strings.HasPrefix(d.Message, "unknown field "):
+		// Note: the decoder doesn't tell where the key is, so its first
+		// occurrence is taken
+		key, _ := strconv.Unquote(strings.TrimPrefix(d.Message, "unknown field "))
+		d.Code = conti.CaseUnknownSetting
+		d.Message = "unknown key '" + key + "'"
+		offset = keyOffset(dat, key)
+	}
+
+	if offset >= 0 && (!upgraded || d.Code == conti.CaseUnknownSetting) {
+		d.Row, d.Column = position(dat, offset)
+	}
+	return d
+}
+
+// keyOffset returns the offset of the first key of a JSON object with the
+// name, or -1 if not found
+func keyOffset(dat []byte, key string) int64 {
+	re := regexp.MustCompile(`"` + regexp.QuoteMeta(key) + `"\s*:`)
+	loc := re.FindIndex(dat)
+	if loc == nil {
+		return -1
+	}
+	return int64(loc[0])
+}
+
+// keyLine returns the 1-based line of the first key of a YAML mapping with
+// the name, or zero if not found
+func keyLine(dat []byte, key string) int {
+	re := regexp.MustCompile(`(?m)(?:^|[ \t{,-])` + regexp.QuoteMeta(key) + `[ \t]*:`)
+	loc := re.FindIndex(dat)
+	if loc == nil {
+		return 0
+	}
+	return bytes.Count(dat[:loc[0]], []byte("\n")) + 1
+}
+
+// keyColumn returns the 1-based column of a key at the 1-based line, or
+// zero if not found
+func keyColumn(dat []byte, line int, key string) int {
+	lines := bytes.Split(dat, []byte("\n"))
+	if line < 1 || line > len(lines) {
+		return 0
+	}
+	return bytes.Index(lines[line-1], []byte(key)) + 1
+}
+
+// position converts an offset in a file to the 1-based line and column
+func position(dat []byte, offset int64) (int, int) {
+	if offset > int64(len(dat)) {
+		offset = int64(len(dat))
 	}
-	return s
+	before := dat[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return line, column
 }
@@ -6,13 +6,22 @@ package main
 
 import (
 	// "fmt"
+	"os"
+	"strings"
 
 	"fyne.io/fyne/app"
 
+	"github.com/serdug/kitri/cli"
 	"github.com/serdug/kitri/ui"
 )
 
 func main() {
+	// Run headless if a command is given, e.g. 'kitri calc template.yaml'
+	// Note: macOS may pass a process serial number '-psn_...' to app bundles
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-psn") {
+		os.Exit(cli.Run(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
 	a := app.NewWithID("io.kitri") // for preferences
 	// a := app.New()
 
@@ -10,6 +10,7 @@ import (
 
 	"fyne.io/fyne"
 	"fyne.io/fyne/dialog"
+	"fyne.io/fyne/storage"
 	"fyne.io/fyne/theme"
 	"fyne.io/fyne/widget"
 
@@ -29,6 +30,10 @@ type kitri struct {
 	// Working directory input
 	wDirInput *widget.Entry
 
+	// Reporting period input
+	periodFrom *widget.Entry
+	periodTo   *widget.Entry
+
 	// Buttons for input chart sections
 	// Examples: 'assets', 'liabls', 'equity', 'revenues', 'expenses'
 	sectEntry map[string]*widget.Button
@@ -47,11 +52,17 @@ type kitri struct {
 	newRecFile *widget.Label
 	newRecName string
 
-	// Names of input chart sections
-	section map[string]string
+	// Input chart sections: file names and rules to read the files
+	section map[string]conti.ChartFile
 
 	// Input records
 	record map[string]conti.Record
+
+	// Loaded template: rules set for the whole template
+	template conti.Schema
+
+	// Levels of the hierarchy of categories shown in the output, all if 0
+	depth int
 }
 
 // newKitri initiates a new Kitri app struct
@@ -61,7 +72,7 @@ func newKitri() *kitri {
 		recEntry:   make(map[string]*recordMenuButton),
 		navigator:  make(map[string]*widget.Button),
 		containers: make(map[string]*fyne.Container),
-		section:    make(map[string]string),
+		section:    make(map[string]conti.ChartFile),
 		record:     make(map[string]conti.Record),
 	}
 }
@@ -178,6 +189,14 @@ func (kit *kitri) navigation(win fyne.Window) {
 		Icon:          theme.NavigateNextIcon(),
 		Text:          "Next",
 		OnTapped: func() {
+			// Note: the review screen isn't shown unless the template is
+			// read without problems
+			s, alert := readSchema(kit.schemaName.Text)
+			if alert.Err != nil {
+				dialog.ShowCustom("Template not loaded", "Close", arrangeProblems(alert), win)
+				return
+			}
+
 			kit.containers["1"].Hide()
 
 			kit.navigator["Load=>Review"].Hide()
@@ -188,7 +207,12 @@ func (kit *kitri) navigation(win fyne.Window) {
 
 			kit.source = "1"
 
-			kit.reviewInput("1", win)
+			kit.reviewInput("1", s, win)
+
+			// Note: e.g. a note of the upgrade of an older template
+			if len(alert.Diagnostics) != 0 {
+				dialog.ShowCustom("Template loaded", "Close", arrangeProblems(alert), win)
+			}
 
 			// fmt.Println("Load->Next")
 		},
@@ -301,7 +325,7 @@ func (kit *kitri) navigation(win fyne.Window) {
 		Text:          "Recalculate and Save",
 		OnTapped: func() {
 			// fmt.Println("Save Output")
-			dialog.ShowFileSave(
+			fd := dialog.NewFileSave(
 				func(writer fyne.URIWriteCloser, err error) {
 					if err != nil {
 						dialog.ShowError(err, win)
@@ -311,10 +335,16 @@ func (kit *kitri) navigation(win fyne.Window) {
 						// fmt.Println("Save cancelled")
 						return
 					}
-					outputWriter(*kit, fileNamed(writer))
+					if alert := outputWriter(*kit, fileNamed(writer)); alert.Err != nil {
+						dialog.ShowCustom("Results not saved", "Close", arrangeProblems(alert), win)
+					}
 				},
 				win,
 			)
+			// Note: results are saved as CSV, as an Excel workbook or as
+			// an HTML page, depending on the extension
+			fd.SetFilter(storage.NewExtensionFileFilter([]string{".csv", ".xlsx", ".html"}))
+			fd.Show()
 		},
 	}
 }
@@ -0,0 +1,123 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package ui for GUI (front end)
+package ui
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+
+	"fyne.io/fyne"
+	"fyne.io/fyne/dialog"
+	"fyne.io/fyne/layout"
+	"fyne.io/fyne/widget"
+
+	"github.com/serdug/kitri/conti"
+)
+
+// Size of the ledger dialog
+const (
+	ledgerWidth  int = 1200
+	ledgerHeight int = 480
+)
+
+// arrangeLedger creates an object showing the records posted to a category
+// with running balances between the opening and the closing balance
+func arrangeLedger(l conti.Ledger) fyne.CanvasObject {
+	// Note: print using localized formatting with golang.org/x/text/message
+	p := message.NewPrinter(language.English)
+
+	titles := []string{"Date", "File", "Row", "Counter", "Description", "Amount", "Balance", "Details"}
+	cols := make([][]fyne.CanvasObject, len(titles))
+	for i, t := range titles {
+		align := fyne.TextAlignLeading
+		if i == 5 || i == 6 {
+			align = fyne.TextAlignTrailing
+		}
+		cols[i] = []fyne.CanvasObject{widget.NewLabelWithStyle(t, align, fyne.TextStyle{Bold: true})}
+	}
+
+	addRow := func(style fyne.TextStyle, field ...string) {
+		for i, txt := range field {
+			align := fyne.TextAlignLeading
+			if i == 5 || i == 6 {
+				align = fyne.TextAlignTrailing
+			}
+			cols[i] = append(cols[i], widget.NewLabelWithStyle(txt, align, style))
+		}
+	}
+
+	bold := fyne.TextStyle{Bold: true}
+	addRow(bold, "", "", "", "", "Opening balance", "", money2txt(p, l.Opening), "")
+	for _, one := range l.Postings {
+		date, row := "", ""
+		if !one.Date.IsZero() {
+			date = one.Date.Format("2006-01-02")
+		}
+		if one.Row > 0 {
+			row = strconv.Itoa(one.Row)
+		}
+
+		// Truncate the description
+		memo := one.Memo
+		if memo == "" {
+			memo = one.CounterName
+		}
+		if len(memo) > symbolsInDescription {
+			memo = memo[0:symbolsInDescription] + "..."
+		}
+		addRow(fyne.TextStyle{}, date, one.File, row, one.Counter, memo, money2txt(p, one.Amount), money2txt(p, one.Balance), details(one.Meta))
+	}
+	addRow(bold, "", "", "", "", "Closing balance", "", money2txt(p, l.Closing), "")
+
+	boxes := make([]fyne.CanvasObject, len(cols))
+	for i, col := range cols {
+		boxes[i] = widget.NewVBox(col...)
+	}
+	return widget.NewHBox(boxes...)
+}
+
+// details joins the other columns of a record with non-empty values, e.g.
+// 'Invoice: 123; Supplier: ABC Ltd'
+func details(meta map[string]string) string {
+	var keys, fields []string
+	for k, v := range meta {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fields = append(fields, k+": "+meta[k])
+	}
+	return strings.Join(fields, "; ")
+}
+
+// ***************************************************************************
+// * METHODS
+// ***************************************************************************
+
+// showLedger shows the records posted to a category in a dialog
+func (kit *kitri) showLedger(cat string, win fyne.Window) {
+	ledgers, alert := conti.AccountsLedger(templateSchema(*kit), cat)
+	if len(alert.Code) != 0 {
+		dialog.ShowCustom("Ledger "+cat, "Close", arrangeProblems(alert), win)
+		return
+	}
+	if len(ledgers) == 0 {
+		return
+	}
+	l := ledgers[0]
+
+	content := fyne.NewContainerWithLayout(
+		layout.NewFixedGridLayout(fyne.NewSize(ledgerWidth, ledgerHeight)),
+		widget.NewVScrollContainer(arrangeLedger(l)),
+	)
+	dialog.ShowCustom("Ledger "+l.Cat+" "+l.Name, "Close", content, win)
+}
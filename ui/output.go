@@ -7,14 +7,14 @@ package ui
 
 import (
 	"fmt"
-	"math"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
 
 	"fyne.io/fyne"
-	"fyne.io/fyne/dialog"
 
 	"fyne.io/fyne/layout"
 	"fyne.io/fyne/theme"
@@ -24,6 +24,9 @@ import (
 )
 
 const symbolsInDescription int = 35
+
+// Option to show all levels of the hierarchy of categories
+const allLevels = "All"
 const splitOffset float64 = 0.37
 
 // templateSchema creates a schema request
@@ -36,13 +39,27 @@ func templateSchema(kit kitri) conti.Schema {
 	recs := len(kit.recEntry)
 	s.Records = make([]conti.Record, recs)
 
+	s.Version = conti.SchemaVersion
+
+	// Note: the working directory may be typed relative to the template
 	s.Path = kit.wDirInput.Text
+	if path, err := conti.ResolvePath(s.Path, filepath.Dir(kit.schemaName.Text)); err == nil {
+		s.Path = path
+	}
 
-	s.Chart.Assets = kit.sectEntry["assets"].Text
-	s.Chart.Liabilities = kit.sectEntry["liabls"].Text
-	s.Chart.Equity = kit.sectEntry["equity"].Text
-	s.Chart.Revenues = kit.sectEntry["revenues"].Text
-	s.Chart.Expenses = kit.sectEntry["expenses"].Text
+	s.Period.From = kit.periodFrom.Text
+	s.Period.To = kit.periodTo.Text
+
+	// Note: keep rules set for the whole template
+	s.Hierarchy = kit.template.Hierarchy
+	s.Closing = kit.template.Closing
+	s.Layout = kit.template.Layout
+
+	// Note: keep sections declared in the template
+	s.Sections = append([]conti.Section(nil), kit.template.Sections...)
+	for _, sect := range s.ChartSections() {
+		s.SetChartFile(sect.Name, kit.chartFile(sect.Name))
+	}
 
 	for i := 0; i < recs; i++ {
 		scount = strconv.Itoa(i + 1)
@@ -52,27 +69,27 @@ func templateSchema(kit kitri) conti.Schema {
 			continue
 		}
 
+		// Note: keep rules to read the file set in the loaded template
+		s.Records[i].Layout = kit.record[recKey].Layout
+		s.Records[i].Format = kit.record[recKey].Format
 		s.Records[i].Id = ent.Text
-		if ent.Icon == theme.CheckButtonCheckedIcon() {
-			s.Records[i].Include = 1
-		} else {
-			s.Records[i].Include = 0
-		}
+		s.Records[i].Include = ent.Icon == theme.CheckButtonCheckedIcon()
 	}
 
 	return s
 }
 
-// arrangeOutput creates an object
-func arrangeOutput(cats []conti.Categories) fyne.CanvasObject {
-	var catTxt, sectTxt, nameTxt, staTxt, difTxt, endTxt *widget.Label
+// arrangeOutput creates an object; a click on a category calls onCat with
+// the category, if set
+func arrangeOutput(cats []conti.Categories, onCat func(string)) fyne.CanvasObject {
+	var sectTxt, nameTxt, staTxt, difTxt, endTxt *widget.Label
 	var cat, sect, name, end, dif, sta string
 
 	// Note: print using localized formatting with golang.org/x/text/message
 	p := message.NewPrinter(language.English)
 
 	catTitle := widget.NewLabelWithStyle("Cat", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
-	sectTitle := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	sectTitle := widget.NewLabelWithStyle("Section", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
 	nameTitle := widget.NewLabelWithStyle("Description", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
 
 	staTitle := widget.NewLabelWithStyle("Starting Value", fyne.TextAlignTrailing, fyne.TextStyle{Bold: true})
@@ -99,28 +116,18 @@ func arrangeOutput(cats []conti.Categories) fyne.CanvasObject {
 		cat = one.Cat
 		sect = one.Sect
 
-		// Truncate the name
+		// Truncate the name and indent it by the level in the hierarchy
 		if len(one.Name) <= symbolsInDescription {
 			name = one.Name
 		} else {
 			name = one.Name[0:symbolsInDescription] + "..."
 		}
+		name = strings.Repeat("    ", one.Level) + name
 
-		sta = p.Sprintf("%.2f", one.Bal.Sta)
-
-		if math.Abs(math.Round(one.Bal.Dif*100)/100) < 0.01 {
-			dif = "0.00"
-		} else {
-			dif = p.Sprintf("%.2f", one.Bal.Dif)
-		}
+		sta = money2txt(p, one.Bal.Sta)
+		dif = money2txt(p, one.Bal.Dif)
+		end = money2txt(p, one.Bal.End)
 
-		if math.Abs(math.Round(one.Bal.End*100)/100) < 0.01 {
-			end = "0.00"
-		} else {
-			end = p.Sprintf("%.2f", one.Bal.End)
-		}
-
-		catTxt = widget.NewLabel(cat)
 		sectTxt = widget.NewLabel(sect)
 		nameTxt = widget.NewLabel(name)
 		staTxt = widget.NewLabel(sta)
@@ -131,7 +138,14 @@ func arrangeOutput(cats []conti.Categories) fyne.CanvasObject {
 		difTxt.Alignment = fyne.TextAlignTrailing
 		endTxt.Alignment = fyne.TextAlignTrailing
 
-		catCol[i+1] = catTxt
+		catCol[i+1] = widget.NewLabel(cat)
+		if onCat != nil {
+			// Note: copy the category for the closure
+			one := cat
+			btn := widget.NewButton(cat, func() { onCat(one) })
+			btn.HideShadow = true
+			catCol[i+1] = btn
+		}
 		sectCol[i+1] = sectTxt
 		nameCol[i+1] = nameTxt
 		staCol[i+1] = staTxt
@@ -149,25 +163,185 @@ func arrangeOutput(cats []conti.Categories) fyne.CanvasObject {
 	return widget.NewHBox(catCont, sectCont, nameCont, staCont, difCont, endCont)
 }
 
+// money2txt formats a monetary value with two decimals and thousand separators
+func money2txt(p *message.Printer, m conti.Money) string {
+	if m.Round(2) == 0 {
+		return "0.00"
+	}
+	return p.Sprintf("%.2f", m.Round(2).Float64())
+}
+
+// arrangeVerdict creates a pass/fail banner of trial balance checks
+func arrangeVerdict(v conti.Verdict) fyne.CanvasObject {
+	banner := widget.NewLabelWithStyle(v.Banner(), fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+
+	lines := []fyne.CanvasObject{banner}
+	for _, d := range v.Imbalances {
+		txt := widget.NewLabel(d.String())
+		txt.Wrapping = fyne.TextWrapWord
+		lines = append(lines, txt)
+	}
+
+	return widget.NewVBox(lines...)
+}
+
+// arrangeStatement creates an object showing a financial statement: the
+// categories under their sections with subtotals and totals
+func arrangeStatement(stmt conti.Statement) fyne.CanvasObject {
+	var name string
+
+	// Note: print using localized formatting with golang.org/x/text/message
+	p := message.NewPrinter(language.English)
+
+	// Add another row for column titles
+	catCol := make([]fyne.CanvasObject, len(stmt.Lines)+1)
+	nameCol := make([]fyne.CanvasObject, len(stmt.Lines)+1)
+	staCol := make([]fyne.CanvasObject, len(stmt.Lines)+1)
+	difCol := make([]fyne.CanvasObject, len(stmt.Lines)+1)
+	endCol := make([]fyne.CanvasObject, len(stmt.Lines)+1)
+
+	// The first row contains column titles
+	catCol[0] = widget.NewLabelWithStyle("Cat", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	nameCol[0] = widget.NewLabelWithStyle(stmt.Title, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	staCol[0] = widget.NewLabelWithStyle("Starting Value", fyne.TextAlignTrailing, fyne.TextStyle{Bold: true})
+	difCol[0] = widget.NewLabelWithStyle("Change", fyne.TextAlignTrailing, fyne.TextStyle{Bold: true})
+	endCol[0] = widget.NewLabelWithStyle("Ending Value", fyne.TextAlignTrailing, fyne.TextStyle{Bold: true})
+
+	for i, one := range stmt.Lines {
+		// Truncate the name and indent it by the level in the hierarchy
+		if len(one.Name) <= symbolsInDescription {
+			name = one.Name
+		} else {
+			name = one.Name[0:symbolsInDescription] + "..."
+		}
+		name = strings.Repeat("    ", one.Level) + name
+
+		// Headings, subtotals and totals are bold; headings have no values
+		style := fyne.TextStyle{Bold: one.Kind != conti.LineItem}
+		sta, dif, end := money2txt(p, one.Value.Sta), money2txt(p, one.Value.Dif), money2txt(p, one.Value.End)
+		if one.Kind == conti.LineHeading {
+			sta, dif, end = "", "", ""
+		}
+
+		catCol[i+1] = widget.NewLabel(one.Cat)
+		nameCol[i+1] = widget.NewLabelWithStyle(name, fyne.TextAlignLeading, style)
+		staCol[i+1] = widget.NewLabelWithStyle(sta, fyne.TextAlignTrailing, style)
+		difCol[i+1] = widget.NewLabelWithStyle(dif, fyne.TextAlignTrailing, style)
+		endCol[i+1] = widget.NewLabelWithStyle(end, fyne.TextAlignTrailing, style)
+	}
+
+	return widget.NewHBox(
+		widget.NewVBox(catCol...),
+		widget.NewVBox(nameCol...),
+		widget.NewVBox(staCol...),
+		widget.NewVBox(difCol...),
+		widget.NewVBox(endCol...),
+	)
+}
+
+// arrangeProblems creates a panel listing all problems found in the input:
+// the error which stopped calculation, if any, followed by the diagnostics
+func arrangeProblems(alert conti.NoticeOfError) fyne.CanvasObject {
+	var lines []fyne.CanvasObject
+
+	if len(alert.Code) != 0 {
+		lines = append(lines, widget.NewLabelWithStyle(alert.Error(), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+	}
+
+	// Note: the hint lists the errors unless they come as diagnostics
+	if alert.Hint != "" && alert.Count(conti.SeverityError) == 0 {
+		hint := widget.NewLabel(alert.Hint)
+		hint.Wrapping = fyne.TextWrapWord
+		lines = append(lines, hint)
+	}
+
+	for _, d := range alert.Diagnostics {
+		txt := widget.NewLabel(d.String())
+		txt.Wrapping = fyne.TextWrapWord
+		lines = append(lines, txt)
+	}
+
+	if len(lines) == 0 {
+		return layout.NewSpacer()
+	}
+	return widget.NewGroup("Problems", lines...)
+}
+
+// arrangeResults creates an object showing the trial balance verdict
+// followed by tabs of the financial statements and of the categories, rolled
+// up and shown to depth levels of the hierarchy; a click on a category calls
+// onCat with the category
+func arrangeResults(cats []conti.Categories, rep conti.Report, depth int, onCat func(string)) fyne.CanvasObject {
+	tabs := widget.NewTabContainer()
+	for _, stmt := range conti.Statements(cats, rep, depth) {
+		tabs.Append(widget.NewTabItem(stmt.Title, arrangeStatement(stmt)))
+	}
+	tabs.Append(widget.NewTabItem("Categories", arrangeOutput(conti.Collapse(conti.RollUp(cats), depth), onCat)))
+
+	return widget.NewVBox(
+		arrangeVerdict(conti.TrialBalance(rep)),
+		tabs,
+	)
+}
+
 // ***************************************************************************
 // * METHODS
 // ***************************************************************************
+// chartFile returns a chart section file selected on the review screen
+// with rules to read the file set in the loaded template
+func (kit *kitri) chartFile(skey string) conti.ChartFile {
+	cf := kit.section[skey]
+	cf.File = kit.sectEntry[skey].Text
+	return cf
+}
+
+// depthSelect creates a selector of the levels of the hierarchy of
+// categories shown in the output
+func (kit *kitri) depthSelect(cats []conti.Categories, win fyne.Window) fyne.CanvasObject {
+	levels := conti.Depth(conti.RollUp(cats))
+	if levels < 2 {
+		return layout.NewSpacer()
+	}
+
+	options := []string{allLevels}
+	for i := 1; i < levels; i++ {
+		options = append(options, strconv.Itoa(i))
+	}
+
+	sel := widget.NewSelect(options, nil)
+	if kit.depth > 0 && kit.depth < levels {
+		sel.SetSelected(strconv.Itoa(kit.depth))
+	} else {
+		sel.SetSelected(allLevels)
+	}
+
+	// Note: set the handler after the initial selection, not to refresh
+	sel.OnChanged = func(value string) {
+		depth, _ := strconv.Atoi(value)
+		if depth == kit.depth {
+			return
+		}
+		kit.depth = depth
+		kit.refreshOutput(win)
+	}
+
+	return widget.NewHBox(
+		widget.NewLabel("Levels of categories"),
+		sel,
+		layout.NewSpacer(),
+	)
+}
+
 // showOutput renders calculation results in a two-column grid container
 func (kit *kitri) showOutput(win fyne.Window) {
 	s := templateSchema(*kit)
 
-	cats, alert := conti.Accounts(s)
+	cats, rep, alert := conti.AccountsReport(s)
 	if len(alert.Code) != 0 {
-		dialog.ShowInformation("Information", alert.Code+"\n"+alert.Hint, win)
-		if alert.Error != nil {
-			dialog.ShowError(alert.Error, win)
-		}
 		fmt.Println(alert.Code)
 	}
 
-	contents := arrangeOutput(cats)
-
-	right := widget.NewVScrollContainer(contents)
+	right := widget.NewVScrollContainer(kit.arrangeCalculation(cats, rep, alert, win))
 
 	kit.containers["3.2"] = fyne.NewContainerWithLayout(
 		layout.NewGridLayout(1),
@@ -187,22 +361,30 @@ func (kit *kitri) showOutput(win fyne.Window) {
 	kit.containers["main"].Refresh()
 }
 
+// arrangeCalculation creates an object showing the problems found in the
+// input followed by the results; no results are shown if the calculation
+// has failed, as they would be wrong
+func (kit *kitri) arrangeCalculation(cats []conti.Categories, rep conti.Report, alert conti.NoticeOfError, win fyne.Window) fyne.CanvasObject {
+	if alert.Err != nil {
+		return widget.NewVBox(arrangeProblems(alert))
+	}
+	return widget.NewVBox(
+		arrangeProblems(alert),
+		kit.depthSelect(cats, win),
+		arrangeResults(cats, rep, kit.depth, func(cat string) { kit.showLedger(cat, win) }),
+	)
+}
+
 // refreshOutput refreshes calculation results
 func (kit *kitri) refreshOutput(win fyne.Window) {
 	s := templateSchema(*kit)
 
-	cats, alert := conti.Accounts(s)
+	cats, rep, alert := conti.AccountsReport(s)
 	if len(alert.Code) != 0 {
-		dialog.ShowInformation("Information", alert.Code+"\n"+alert.Hint, win)
-		if alert.Error != nil {
-			dialog.ShowError(alert.Error, win)
-		}
 		fmt.Println(alert.Code)
 	}
 
-	contents := arrangeOutput(cats)
-
-	right := widget.NewVScrollContainer(contents)
+	right := widget.NewVScrollContainer(kit.arrangeCalculation(cats, rep, alert, win))
 
 	kit.containers["3"].Hide()
 
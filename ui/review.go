@@ -25,29 +25,51 @@ import (
 
 const recPrefix string = "rec-"
 
-var sectionKeys = [...]string{"assets", "liabls", "equity", "revenues", "expenses"}
+// Extensions of files with charts and records
+var inputExts = []string{".csv", ".xlsx", ".ods"}
 
-// ***************************************************************************
-// * METHODS
-// ***************************************************************************
-func (kit *kitri) reviewInput(source string, win fyne.Window) {
-	var s conti.Schema
-
-	// Note: clean up old records before loading a config
-	kit.recEntry = make(map[string]*recordMenuButton)
+// readSchema reads a template from a YAML or JSON file
+func readSchema(filename string) (conti.Schema, conti.NoticeOfError) {
+	var (
+		s     conti.Schema
+		alert conti.NoticeOfError
+	)
 
-	fileExt := filepath.Ext(kit.schemaName.Text)
+	fileExt := strings.ToLower(filepath.Ext(filename))
 
 	switch fileExt {
 	case ".json":
-		s = handlers.ReadSchemaJSON(kit.schemaName.Text)
+		s, alert = handlers.ReadSchemaJSON(filename)
 
-	case ".yaml":
-		s = handlers.ReadSchemaYAML(kit.schemaName.Text)
+	case ".yaml", ".yml":
+		s, alert = handlers.ReadSchemaYAML(filename)
 
-	case ".yml":
-		s = handlers.ReadSchemaYAML(kit.schemaName.Text)
+	default:
+		alert = conti.NoticeOfError{
+			Code:     conti.CaseWrongFileType,
+			Resource: filename,
+			Hint:     "Select a template file (.yaml, .yml or .json)",
+			Err:      fmt.Errorf("unacceptable template type '%s'", fileExt),
+		}
+	}
+
+	if alert.Err != nil {
+		alert.Trace.Crumbs("readSchema")
 	}
+	return s, alert
+}
+
+// ***************************************************************************
+// * METHODS
+// ***************************************************************************
+
+// reviewInput shows the template s read from the file for review
+func (kit *kitri) reviewInput(source string, s conti.Schema, win fyne.Window) {
+	// Note: clean up old records before loading a config
+	kit.recEntry = make(map[string]*recordMenuButton)
+	kit.record = make(map[string]conti.Record)
+
+	kit.template = s
 
 	left := kit.makeChartGroup(s, win)
 
@@ -101,7 +123,7 @@ func (kit *kitri) reviewInput(source string, win fyne.Window) {
 		addButton,
 	)
 
-	noteAddRecords := widget.NewLabel("Files (.csv) with records may be added to or erased from the input")
+	noteAddRecords := widget.NewLabel("Files (.csv) and sheets of workbooks (.xlsx, .ods) with records may be added to or erased from the input")
 	noteAddRecords.Wrapping = fyne.TextWrapWord
 
 	records := widget.NewVBox(
@@ -132,72 +154,52 @@ func (kit *kitri) reviewInput(source string, win fyne.Window) {
 // makeChartGroup renders chart sections (and a path to working directory)
 // with file names for review / edit
 func (kit *kitri) makeChartGroup(s conti.Schema, win fyne.Window) fyne.Widget {
-	var sectionBox *widget.Box
-
 	pathEntry := widget.NewEntry()
 	kit.wDirInput = pathEntry
 	kit.wDirInput.SetText(s.Path)
 
-	slist := make([]fyne.CanvasObject, len(sectionKeys))
-	for i, skey := range sectionKeys {
-		kit.sectionButton(skey, win)
+	// Note: sections are keyed by their names
+	sections := s.ChartSections()
+	kit.section = make(map[string]conti.ChartFile)
+	kit.sectEntry = make(map[string]*widget.Button)
 
-		switch skey {
-		case "assets":
-			sectionBox = widget.NewVBox(
-				widget.NewLabel("Assets:"),
-				kit.sectEntry["assets"],
-			)
-			kit.section["assets"] = s.Chart.Assets
-			kit.sectEntry["assets"].Text = s.Chart.Assets
-
-		case "liabls":
-			sectionBox = widget.NewVBox(
-				widget.NewLabel("Liabilities:"),
-				kit.sectEntry["liabls"],
-			)
-			kit.section["liabls"] = s.Chart.Liabilities
-			kit.sectEntry["liabls"].Text = s.Chart.Liabilities
-
-		case "equity":
-			sectionBox = widget.NewVBox(
-				widget.NewLabel("Equity:"),
-				kit.sectEntry["equity"],
-			)
-			kit.section["equity"] = s.Chart.Equity
-			kit.sectEntry["equity"].Text = s.Chart.Equity
+	slist := make([]fyne.CanvasObject, len(sections))
+	for i, sect := range sections {
+		skey := sect.Name
+		kit.sectionButton(skey, win)
 
-		case "revenues":
-			sectionBox = widget.NewVBox(
-				widget.NewLabel("Revenues:"),
-				kit.sectEntry["revenues"],
-			)
-			kit.section["revenues"] = s.Chart.Revenues
-			kit.sectEntry["revenues"].Text = s.Chart.Revenues
+		kit.section[skey] = sect.ChartFile()
+		kit.sectEntry[skey].Text = sect.File
 
-		case "expenses":
-			sectionBox = widget.NewVBox(
-				widget.NewLabel("Expenses:"),
-				kit.sectEntry["expenses"],
-			)
-			kit.section["expenses"] = s.Chart.Expenses
-			kit.sectEntry["expenses"].Text = s.Chart.Expenses
-
-		default:
-			// fmt.Printf("Chart section key %s not found\n", skey)
-			dialog.ShowInformation("Warning", "Chart section key '"+skey+"'' not found!", win)
-		}
-		slist[i] = sectionBox
+		slist[i] = widget.NewVBox(
+			widget.NewLabel(skey+":"),
+			kit.sectEntry[skey],
+		)
 	}
 
 	chartGroup := widget.NewGroup("Chart of Accounts", slist...)
 
 	labelWorkDir := widget.NewLabel("Working directory:")
 
+	fromEntry := widget.NewEntry()
+	fromEntry.SetPlaceHolder("from YYYY-MM-DD")
+	kit.periodFrom = fromEntry
+	kit.periodFrom.SetText(s.Period.From)
+
+	toEntry := widget.NewEntry()
+	toEntry.SetPlaceHolder("to YYYY-MM-DD")
+	kit.periodTo = toEntry
+	kit.periodTo.SetText(s.Period.To)
+
+	labelPeriod := widget.NewLabel("Reporting period (optional):")
+
 	return widget.NewVBox(
 		chartGroup,
 		labelWorkDir,
 		pathEntry,
+		labelPeriod,
+		fromEntry,
+		toEntry,
 	)
 }
 
@@ -226,7 +228,7 @@ func (kit *kitri) sectionButton(skey string, win fyne.Window) {
 			fileExt := file.URI().Extension()
 			fileExt = strings.ToLower(fileExt)
 
-			if fileExt == ".csv" {
+			if inputExt(fileExt) {
 				p := fmt.Sprintf("%s", file.URI())
 
 				// Remove "file://" from file.URI() added by fyne
@@ -238,39 +240,31 @@ func (kit *kitri) sectionButton(skey string, win fyne.Window) {
 				// using Join() from path/filepath
 				d, f = filepath.Split(p)
 
-				switch skey {
-				case "assets":
-					kit.sectEntry["assets"].SetText(f)
-					// fmt.Println("Assets - replaced:", "(file '", f, "')")
-
-				case "liabls":
-					kit.sectEntry["liabls"].SetText(f)
-					// fmt.Println("Liabilities - replaced:", "(file '", f, "')")
-
-				case "equity":
-					kit.sectEntry["equity"].SetText(f)
-					// fmt.Println("Equity - replaced:", "(file '", f, "')")
-
-				case "revenues":
-					kit.sectEntry["revenues"].SetText(f)
-					// fmt.Println("Revenues - replaced:", "(file '", f, "')")
-
-				case "expenses":
-					kit.sectEntry["expenses"].SetText(f)
-					// fmt.Println("Expenses - replaced:", "(file '", f, "')")
-				}
-
-				// TO DO: WARN of a wrong directory!!!
-				kit.wDirInput.SetText(d)
+				pickSheet(p, win, func(sheet string) {
+					if sheet != "" {
+						f += "#" + sheet
+					}
+					kit.replaceSection(skey, f, d)
+				})
 			}
 
 		}, win)
-		extFilter := storage.NewExtensionFileFilter([]string{".csv"})
+		extFilter := storage.NewExtensionFileFilter(inputExts)
 		fd.SetFilter(extFilter)
 		fd.Show()
 	}
 }
 
+// replaceSection replaces the file of a section by its idenifier 'skey'
+func (kit *kitri) replaceSection(skey, f, d string) {
+	if entry, ok := kit.sectEntry[skey]; ok {
+		entry.SetText(f)
+	}
+
+	// TO DO: WARN of a wrong directory!!!
+	kit.wDirInput.SetText(d)
+}
+
 // arrangeRecords renders files with records for review
 func (kit *kitri) arrangeRecords(s conti.Schema, win fyne.Window) fyne.CanvasObject {
 	var (
@@ -296,7 +290,7 @@ func (kit *kitri) arrangeRecords(s conti.Schema, win fyne.Window) fyne.CanvasObj
 		rec := newRecordMenuButton()
 		rec.Alignment = widget.ButtonAlignLeading
 		rec.Text = s.Records[i].Id
-		if s.Records[i].Include != 1 {
+		if !s.Records[i].Include {
 			rec.Icon = theme.CheckButtonIcon()
 
 			rec.menu = fyne.NewMenu(
@@ -311,8 +305,10 @@ func (kit *kitri) arrangeRecords(s conti.Schema, win fyne.Window) fyne.CanvasObj
 			)
 
 			kit.record[recKey] = conti.Record{
-				Include: 0,
+				Include: false,
 				Id:      s.Records[i].Id,
+				Format:  s.Records[i].Format,
+				Layout:  s.Records[i].Layout,
 			}
 		} else {
 			rec.Icon = theme.CheckButtonCheckedIcon()
@@ -329,8 +325,10 @@ func (kit *kitri) arrangeRecords(s conti.Schema, win fyne.Window) fyne.CanvasObj
 			)
 
 			kit.record[recKey] = conti.Record{
-				Include: 1,
+				Include: true,
 				Id:      s.Records[i].Id,
+				Format:  s.Records[i].Format,
+				Layout:  s.Records[i].Layout,
 			}
 		}
 
@@ -362,7 +360,7 @@ func (kit *kitri) recordDialog(win fyne.Window) {
 		fileExt := file.URI().Extension()
 		fileExt = strings.ToLower(fileExt)
 
-		if fileExt == ".csv" {
+		if inputExt(fileExt) {
 			p := fmt.Sprintf("%s", file.URI())
 
 			// Remove "file://" from file.URI() added by fyne
@@ -377,13 +375,18 @@ func (kit *kitri) recordDialog(win fyne.Window) {
 				// fmt.Println("Add record->Wrong directory")
 				return
 			}
-			kit.newRecName = f
-			kit.newRecFile.SetText(f)
-			// fmt.Println("recordDialog:", kit.newRecFile.Text, "(file '", f, "')")
+			pickSheet(p, win, func(sheet string) {
+				if sheet != "" {
+					f += "#" + sheet
+				}
+				kit.newRecName = f
+				kit.newRecFile.SetText(f)
+				// fmt.Println("recordDialog:", kit.newRecFile.Text, "(file '", f, "')")
+			})
 		}
 
 	}, win)
-	extFilter := storage.NewExtensionFileFilter([]string{".csv"})
+	extFilter := storage.NewExtensionFileFilter(inputExts)
 	fd.SetFilter(extFilter)
 	fd.Show()
 }
@@ -442,3 +445,36 @@ func (b *recordMenuButton) Tapped(e *fyne.PointEvent) {
 	}
 	widget.ShowPopUpMenuAtPosition(b.menu, fyne.CurrentApp().Driver().CanvasForObject(b), e.AbsolutePosition)
 }
+
+// inputExt reports whether a file with the extension may be taken as input
+func inputExt(ext string) bool {
+	for _, each := range inputExts {
+		if ext == each {
+			return true
+		}
+	}
+	return false
+}
+
+// pickSheet asks which sheet of a workbook to read and passes its name on;
+// an empty name is passed on for other files
+func pickSheet(p string, win fyne.Window, done func(sheet string)) {
+	sheets, err := conti.Sheets(p)
+	if err != nil {
+		dialog.ShowError(err, win)
+		return
+	}
+	if len(sheets) == 0 {
+		done("")
+		return
+	}
+
+	choice := widget.NewSelect(sheets, nil)
+	choice.SetSelected(sheets[0])
+
+	dialog.ShowCustomConfirm("Select a sheet", "OK", "Cancel", choice, func(ok bool) {
+		if ok && choice.Selected != "" {
+			done(choice.Selected)
+		}
+	}, win)
+}
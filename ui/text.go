@@ -35,7 +35,7 @@ Use 'Select file' to load a saved configuration. The template can be edited and
 
 DATA INPUT FORMAT
 
-Kitri takes files with user-defined tables of categories (i.e. the Chart of Accounts) and records of transactions as input. The input files should be prepared beforehand by users in spreadsheets. The files should be saved in the CSV (Comma Separated Values) format. Any single spreadsheet may be saved as a .csv file from MS Excel, LibreOffice Calc or Google Sheets. The CSV format preserves cell values and the structure of rows and columns. Formulas are omitted, although the number formatting remains. So please make sure that the number format is set to General / Automatic before saving data as CSV.
+Kitri takes files with user-defined tables of categories (i.e. the Chart of Accounts) and records of transactions as input. The input files should be prepared beforehand by users in spreadsheets. The files should be saved in the CSV (Comma Separated Values) format, or kept as MS Excel (.xlsx) or LibreOffice Calc (.ods) workbooks; a sheet of a workbook is referred to by its name after '#', e.g. 'book.xlsx#Assets'. Any single spreadsheet may be saved as a .csv file from MS Excel, LibreOffice Calc or Google Sheets. The CSV format preserves cell values and the structure of rows and columns. Formulas are omitted, although the number formatting remains. So please make sure that the number format is set to General / Automatic before saving data as CSV, or set the number format (decimal and thousands separators, currency symbols, negatives in parentheses) in the template.
 
 Kitri allows to customize the accounts according to user needs and vary the level of detail appropriately.
 
@@ -54,6 +54,7 @@ The following column order must be respected:
 • Amount - taken as a number (no thousand separators!), the monetary value of transaction 
 • Source - taken as a character string, category ID; it is where the money goes from
 • Purpose - taken as a character string, category ID; it is where the money goes to 
+• Date - optional, the date of transaction, e.g. 2020-03-31; records before the reporting period are carried into starting balances, records after it are left out
 
 The other columns may contain any comments, notes or explanations. They are ignored by the calculator. It is assumed that the first row of data contains column titles. The first row is ignored. So, all columns may be given any names. 
 `
@@ -7,15 +7,13 @@ package ui
 
 import (
 	"fmt"
-	"io/ioutil"
 	"path/filepath"
 	"strings"
 
-	"gopkg.in/yaml.v2"
-
 	"fyne.io/fyne"
 
 	"github.com/serdug/kitri/conti"
+	"github.com/serdug/kitri/handlers"
 )
 
 // fileNamed extracts the file name from fyne's 'URIWriteCloser' object,
@@ -48,59 +46,68 @@ func schemaWriter(kit kitri, name string) {
 		fmt.Println("Template saved to", name)
 
 	default:
-		fmt.Printf("File '" + name +
+		fmt.Print("File '" + name +
 			"' has an unacceptable extension '" + ext +
-			"'\nTemplates are only saved as '.yaml' (or '.yml') files.\nPlease set a file name without extension or type it with a YAML extension.")
+			"'\nTemplates are only saved as '.yaml' (or '.yml') files.\nPlease set a file name without extension or type it with a YAML extension.\n")
 		return
 	}
 }
 
-// outputWriter recalculates and saves results as a '.csv' file
-func outputWriter(kit kitri, name string) {
+// outputWriter recalculates and saves results as a '.csv' file, as an
+// '.xlsx' workbook or as an '.html' page of financial statements; nothing is
+// saved if the calculation fails, and the notice of error is returned
+func outputWriter(kit kitri, name string) conti.NoticeOfError {
 	schema := templateSchema(kit)
-	cats, _ := conti.Accounts(schema)
+	cats, rep, alert := conti.AccountsReport(schema)
+	if alert.Err != nil {
+		alert.Trace.Crumbs("outputWriter")
+		return alert
+	}
 
 	ext := filepath.Ext(name)
 	ext = strings.ToLower(ext)
 
 	switch {
 	case ext == ".":
-		conti.ExportAccountsToCsv(cats, name+"csv")
-		fmt.Println("Output saved to", name+"csv")
+		conti.ExportAccountsToCsv(cats, rep, kit.depth, name+"csv")
+		fmt.Println("Output saved to", name+"csv", "and", conti.StatementsFile(name+"csv"))
 
 	case len(ext) == 0:
-		conti.ExportAccountsToCsv(cats, name+".csv")
-		fmt.Println("Output saved to", name+".csv")
+		conti.ExportAccountsToCsv(cats, rep, kit.depth, name+".csv")
+		fmt.Println("Output saved to", name+".csv", "and", conti.StatementsFile(name+".csv"))
 
 	case ext == ".csv":
 		// Save with a user-typed name
-		conti.ExportAccountsToCsv(cats, name)
+		conti.ExportAccountsToCsv(cats, rep, kit.depth, name)
+		fmt.Println("Output saved to", name, "and", conti.StatementsFile(name))
+
+	case ext == ".xlsx":
+		// Save as a workbook with a user-typed name
+		conti.ExportAccountsToXlsx(cats, rep, kit.depth, name)
+		fmt.Println("Output saved to", name)
+
+	case ext == ".html" || ext == ".htm":
+		// Save financial statements with a user-typed name
+		conti.ExportAccountsToHtml(cats, rep, kit.depth, name)
 		fmt.Println("Output saved to", name)
 
 	default:
-		fmt.Printf("File '" + name +
+		fmt.Print("File '" + name +
 			"' has an unacceptable extension '" + ext +
-			"'\nResults are only saved as '.csv' files.\nPlease set a file name without extension or type it with a CSV extension.")
-		return
+			"'\nResults are only saved as '.csv', '.xlsx' or '.html' files.\nPlease set a file name without extension or type it with a CSV, XLSX or HTML extension.\n")
 	}
+	return alert
 }
 
 // writeSchemaYAML serializes and writes a schema template into a YAML file
-// with a provided name
+// with a provided name; the working directory is saved relative to the
+// file where possible
 func writeSchemaYAML(kit kitri, filename string) {
 	s := templateSchema(kit)
 
-	// Serialize schema into a YAML document
-	data, err := yaml.Marshal(s)
-
+	err := handlers.WriteSchemaYAML(filename, s)
 	if err != nil {
-		fmt.Println("YAML Marshal error:", err)
-		return
-	}
-
-	erw := ioutil.WriteFile(filename, data, 0644)
-	if erw != nil {
-		fmt.Println("Writing error:", erw)
+		fmt.Println("Writing error:", err)
 		return
 	}
 }